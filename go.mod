@@ -11,7 +11,7 @@ require (
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
 	github.com/openshift/custom-resource-status v0.0.0-20200602122900-c002fd1547ca
-	github.com/prometheus/client_golang v1.1.0 // indirect
+	github.com/prometheus/client_golang v1.1.0
 	go.uber.org/multierr v1.3.0 // indirect
 	golang.org/x/tools v0.0.0-20200115044656-831fdb1e1868 // indirect
 	k8s.io/api v0.18.6
@@ -2,6 +2,7 @@ package api
 
 import (
 	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Phase is the current phase of the deployment
@@ -30,6 +31,61 @@ const (
 	PhaseEmpty Phase = ""
 )
 
+// UpgradeResult is the result of a finished upgrade
+type UpgradeResult string
+
+const (
+	// UpgradeSucceeded signals that the upgrade completed successfully
+	UpgradeSucceeded UpgradeResult = "Succeeded"
+
+	// UpgradeFailed signals that the upgrade failed
+	UpgradeFailed UpgradeResult = "Failed"
+)
+
+// UpgradeProgress represents the upgrade in progress
+type UpgradeProgress struct {
+	// The version the upgrade started from
+	FromVersion string `json:"fromVersion,omitempty" optional:"true"`
+	// The version being upgraded to
+	ToVersion string `json:"toVersion"`
+	// The time the upgrade started
+	StartTime metav1.Time `json:"startTime"`
+	// The number of managed resources reconciled to the target version
+	UpgradedResources int32 `json:"upgradedResources"`
+	// The number of managed resources
+	TotalResources int32 `json:"totalResources"`
+}
+
+// UpgradeRecord represents a finished upgrade
+type UpgradeRecord struct {
+	// The version the upgrade started from
+	FromVersion string `json:"fromVersion,omitempty" optional:"true"`
+	// The version upgraded to
+	ToVersion string `json:"toVersion"`
+	// The time the upgrade started
+	StartTime metav1.Time `json:"startTime"`
+	// The time the upgrade finished
+	FinishTime metav1.Time `json:"finishTime"`
+	// The result of the upgrade
+	Result UpgradeResult `json:"result"`
+}
+
+// ManagedResource identifies a resource applied by the operator
+type ManagedResource struct {
+	// The API group of the resource
+	Group string `json:"group,omitempty" optional:"true"`
+	// The API version of the resource
+	Version string `json:"version"`
+	// The kind of the resource
+	Kind string `json:"kind"`
+	// The namespace of the resource; empty for cluster-scoped resources
+	Namespace string `json:"namespace,omitempty" optional:"true"`
+	// The name of the resource
+	Name string `json:"name"`
+	// The operator version, which last applied the resource
+	OperatorVersion string `json:"operatorVersion,omitempty" optional:"true"`
+}
+
 // Status represents status of a operator configuration resource; must be inlined in the operator configuration resource status
 type Status struct {
 	Phase Phase `json:"phase,omitempty"`
@@ -41,6 +97,18 @@ type Status struct {
 	TargetVersion string `json:"targetVersion,omitempty" optional:"true"`
 	// The observed version of the resource
 	ObservedVersion string `json:"observedVersion,omitempty" optional:"true"`
+	// The rollout wave of the managed resources that is being deployed
+	RolloutWave *int32 `json:"rolloutWave,omitempty" optional:"true"`
+	// The name of the last migration completed during the current upgrade
+	LastMigration string `json:"lastMigration,omitempty" optional:"true"`
+	// The upgrade in progress
+	UpgradeInProgress *UpgradeProgress `json:"upgradeInProgress,omitempty" optional:"true"`
+	// The finished upgrades, the most recent first
+	UpgradeHistory []UpgradeRecord `json:"upgradeHistory,omitempty" optional:"true"`
+	// The resources applied by the operator
+	Inventory []ManagedResource `json:"inventory,omitempty" optional:"true"`
+	// Whether the inventory has been initialized, so that it lists all the resources applied by the operator
+	InventoryInitialized bool `json:"inventoryInitialized,omitempty" optional:"true"`
 }
 
 // DeepCopyInto is copying the receiver, writing into out. in must be non-nil.
@@ -53,4 +121,39 @@ func (in *Status) DeepCopyInto(out *Status) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RolloutWave != nil {
+		in, out := &in.RolloutWave, &out.RolloutWave
+		*out = new(int32)
+		**out = **in
+	}
+	if in.UpgradeInProgress != nil {
+		in, out := &in.UpgradeInProgress, &out.UpgradeInProgress
+		*out = new(UpgradeProgress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpgradeHistory != nil {
+		in, out := &in.UpgradeHistory, &out.UpgradeHistory
+		*out = make([]UpgradeRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = make([]ManagedResource, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopyInto is copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeProgress) DeepCopyInto(out *UpgradeProgress) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+}
+
+// DeepCopyInto is copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeRecord) DeepCopyInto(out *UpgradeRecord) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.FinishTime.DeepCopyInto(&out.FinishTime)
 }
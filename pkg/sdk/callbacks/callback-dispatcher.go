@@ -18,7 +18,7 @@ package callbacks
 
 import (
 	"context"
-	"reflect"
+	"fmt"
 
 	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
 
@@ -26,7 +26,9 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
 const (
@@ -75,7 +77,7 @@ type ReconcileCallbackArgs struct {
 // CallbackDispatcher manages and executes resource callbacks
 type CallbackDispatcher struct {
 	log       logr.Logger
-	callbacks map[reflect.Type][]ReconcileCallback
+	callbacks map[schema.GroupVersionKind][]ReconcileCallback
 	// This Client, initialized using mgr.client() above, is a split Client
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
@@ -98,29 +100,47 @@ func NewCallbackDispatcher(log logr.Logger, client, uncachedClient client.Client
 		uncachedClient: uncachedClient,
 		scheme:         scheme,
 		namespace:      namespace,
-		callbacks:      make(map[reflect.Type][]ReconcileCallback),
+		callbacks:      make(map[schema.GroupVersionKind][]ReconcileCallback),
 	}
 }
 
-// AddCallback registers a callback for given object type
+// AddCallback registers a callback for given object kind; callbacks registered for nil object are invoked for all the kinds.
+// The callback is not registered when the kind of a typed object can't be resolved with the scheme; the error is logged
 func (cd *CallbackDispatcher) AddCallback(obj runtime.Object, cb ReconcileCallback) {
-	t := reflect.TypeOf(obj)
-	cbs := cd.callbacks[t]
-	cd.callbacks[t] = append(cbs, cb)
+	if err := cd.AddCallbackE(obj, cb); err != nil {
+		cd.log.Error(err, "Unable to register callback", "type", fmt.Sprintf("%T", obj))
+	}
+}
+
+// AddCallbackE registers a callback for given object kind like AddCallback, but returns error when the kind of a typed
+// object can't be resolved with the scheme
+func (cd *CallbackDispatcher) AddCallbackE(obj runtime.Object, cb ReconcileCallback) error {
+	gvk, err := cd.gvkFor(obj)
+	if err != nil {
+		return err
+	}
+	cbs := cd.callbacks[gvk]
+	cd.callbacks[gvk] = append(cbs, cb)
+	return nil
 }
 
-// InvokeCallbacks executes callbacks for desired/current object type
+// InvokeCallbacks executes callbacks for desired/current object kind
 func (cd *CallbackDispatcher) InvokeCallbacks(l logr.Logger, cr interface{}, s ReconcileState, desiredObj, currentObj runtime.Object) error {
-	var t reflect.Type
+	var t schema.GroupVersionKind
+	var err error
 
 	if desiredObj != nil {
-		t = reflect.TypeOf(desiredObj)
+		t, err = cd.gvkFor(desiredObj)
 	} else if currentObj != nil {
-		t = reflect.TypeOf(currentObj)
+		t, err = cd.gvkFor(currentObj)
+	}
+	if err != nil {
+		return err
 	}
 
-	// callbacks with nil key always get invoked
-	cbs := append(cd.callbacks[t], cd.callbacks[nil]...)
+	// callbacks with empty key always get invoked
+	// copy, so that concurrent invocations do not share the backing array
+	cbs := append(append([]ReconcileCallback(nil), cd.callbacks[t]...), cd.callbacks[schema.GroupVersionKind{}]...)
 
 	for _, cb := range cbs {
 		if s != ReconcileStatePreCreate && currentObj == nil {
@@ -158,3 +178,10 @@ func (cd *CallbackDispatcher) InvokeCallbacks(l logr.Logger, cr interface{}, s R
 
 	return nil
 }
+
+func (cd *CallbackDispatcher) gvkFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	if obj == nil {
+		return schema.GroupVersionKind{}, nil
+	}
+	return apiutil.GVKForObject(obj, cd.scheme)
+}
@@ -9,6 +9,8 @@ import (
 	. "github.com/onsi/gomega"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -201,4 +203,43 @@ var _ = Describe("Callback dispatcher ", func() {
 		Expect(err).To(Equal(callbackError))
 	})
 
+	It("should return error for kind unknown to the scheme", func() {
+		dispatcher := callbacks.NewCallbackDispatcher(log, client, client, runtime.NewScheme(), namespace)
+		callback := func(args *callbacks.ReconcileCallbackArgs) error {
+			return nil
+		}
+
+		err := dispatcher.AddCallbackE(&v1.Pod{}, callback)
+		Expect(err).To(HaveOccurred())
+		Expect(func() { dispatcher.AddCallback(&v1.Pod{}, callback) }).ToNot(Panic())
+	})
+
+	It("should invoke callbacks registered for the kind of unstructured object", func() {
+		dispatcher := callbacks.NewCallbackDispatcher(log, client, client, s, namespace)
+		cr := testcr.Config{}
+		reconcileState := callbacks.ReconcileStatePreCreate
+
+		var invokedFor []runtime.Object
+		callback := func(args *callbacks.ReconcileCallbackArgs) error {
+			invokedFor = append(invokedFor, args.DesiredObject)
+			return nil
+		}
+
+		By("registering callback for typed object")
+		dispatcher.AddCallback(&v1.ConfigMap{}, callback)
+
+		By("invoking callbacks for unstructured objects")
+		configMap := &unstructured.Unstructured{}
+		configMap.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("ConfigMap"))
+		secret := &unstructured.Unstructured{}
+		secret.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Secret"))
+
+		err := dispatcher.InvokeCallbacks(log, cr, reconcileState, configMap, nil)
+		Expect(err).ToNot(HaveOccurred())
+		err = dispatcher.InvokeCallbacks(log, cr, reconcileState, secret, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(invokedFor).To(ConsistOf(configMap))
+	})
+
 })
@@ -35,6 +35,19 @@ func ConditionsChanged(originalValues, newValues map[v1.ConditionType]v12.Condit
 	return false
 }
 
+// ConditionMessagesChanged compares reasons and messages of the conditions and returns true if any of them changed, false otherwise.
+func ConditionMessagesChanged(originalConditions, newConditions []v1.Condition) bool {
+	for _, newCondition := range newConditions {
+		originalCondition := v1.FindStatusCondition(originalConditions, newCondition.Type)
+		if originalCondition == nil ||
+			originalCondition.Reason != newCondition.Reason ||
+			originalCondition.Message != newCondition.Message {
+			return true
+		}
+	}
+	return false
+}
+
 // MarkCrHealthyMessage marks the passed in CR as healthy. The CR object needs to be updated by the caller afterwards.
 // Healthy means the following status conditions are set:
 // ApplicationAvailable: true
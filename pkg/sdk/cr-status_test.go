@@ -268,4 +268,12 @@ var _ = Describe("For Conditions", func() {
 			map[v1.ConditionType]v12.ConditionStatus{v1.ConditionAvailable: v12.ConditionTrue},
 		),
 	)
+
+	It("should detect changed condition message", func() {
+		original := []v1.Condition{{Type: v1.ConditionDegraded, Status: v12.ConditionTrue, Reason: "ResourcesNotReady", Message: "first"}}
+		changed := []v1.Condition{{Type: v1.ConditionDegraded, Status: v12.ConditionTrue, Reason: "ResourcesNotReady", Message: "second"}}
+
+		Expect(sdk.ConditionMessagesChanged(original, original)).To(BeFalse())
+		Expect(sdk.ConditionMessagesChanged(original, changed)).To(BeTrue())
+	})
 })
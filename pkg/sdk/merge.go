@@ -0,0 +1,112 @@
+package sdk
+
+import (
+	"encoding/json"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// builtInScheme recognizes the Kubernetes built-in types, which carry the patch strategy metadata
+var builtInScheme = newBuiltInScheme()
+
+func newBuiltInScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// MergeStrategy merges desired object into the current one using the last applied configuration stored in the given annotation
+// and returns the merged object; currentObj must not be modified
+type MergeStrategy func(desiredObj, currentObj runtime.Object, lastAppliedConfigAnnotation string) (runtime.Object, error)
+
+// MergeStrategyRegistry keeps merge strategies registered for object kinds
+type MergeStrategyRegistry struct {
+	strategies map[schema.GroupVersionKind]MergeStrategy
+}
+
+// NewMergeStrategyRegistry creates new MergeStrategyRegistry. ConfigMaps and Secrets are registered with KeepCurrentObject,
+// so that their content can be changed by the users. Deployments, DaemonSets and StatefulSets are registered with
+// MergeObject, so that their pod templates converge to the desired ones, i.e. containers and env vars added by someone
+// else are reverted
+func NewMergeStrategyRegistry() *MergeStrategyRegistry {
+	registry := &MergeStrategyRegistry{
+		strategies: make(map[schema.GroupVersionKind]MergeStrategy),
+	}
+	registry.Register(v1.SchemeGroupVersion.WithKind("ConfigMap"), KeepCurrentObject)
+	registry.Register(v1.SchemeGroupVersion.WithKind("Secret"), KeepCurrentObject)
+	registry.Register(appsv1.SchemeGroupVersion.WithKind("Deployment"), MergeObject)
+	registry.Register(appsv1.SchemeGroupVersion.WithKind("DaemonSet"), MergeObject)
+	registry.Register(appsv1.SchemeGroupVersion.WithKind("StatefulSet"), MergeObject)
+	return registry
+}
+
+// Register registers merge strategy for given object kind, replacing the previously registered one
+func (r *MergeStrategyRegistry) Register(gvk schema.GroupVersionKind, strategy MergeStrategy) {
+	r.strategies[gvk] = strategy
+}
+
+// StrategyFor returns the merge strategy for given object kind. Built-in kinds without registered strategy are merged
+// with StrategicMergeObject, which preserves list items added by someone else. Other kinds, i.e. custom resources, are
+// merged with MergeObject
+func (r *MergeStrategyRegistry) StrategyFor(gvk schema.GroupVersionKind) MergeStrategy {
+	if strategy, ok := r.strategies[gvk]; ok {
+		return strategy
+	}
+	if builtInScheme.Recognizes(gvk) {
+		return mergeBuiltInObject
+	}
+	return MergeObject
+}
+
+// mergeBuiltInObject merges typed built-in objects with StrategicMergeObject; unstructured objects lack the patch
+// strategy metadata, so they are merged with MergeObject
+func mergeBuiltInObject(desiredObj, currentObj runtime.Object, lastAppliedConfigAnnotation string) (runtime.Object, error) {
+	if _, ok := currentObj.(*unstructured.Unstructured); ok {
+		return MergeObject(desiredObj, currentObj, lastAppliedConfigAnnotation)
+	}
+	return StrategicMergeObject(desiredObj, currentObj, lastAppliedConfigAnnotation)
+}
+
+// StrategicMergeObject merges desired object into the current one using three-way strategic merge patch. It works only for
+// types with patch strategy metadata (i.e. Kubernetes built-in types) and, unlike MergeObject, preserves list items added
+// by other parties (i.e. sidecar containers)
+func StrategicMergeObject(desiredObj, currentObj runtime.Object, lastAppliedConfigAnnotation string) (runtime.Object, error) {
+	original, modified, current, err := mergeInput(desiredObj, currentObj, lastAppliedConfigAnnotation)
+	if err != nil {
+		return nil, err
+	}
+
+	lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(currentObj)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, lookupPatchMeta, true, mergePreconditions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	newCurrent, err := strategicpatch.StrategicMergePatchUsingLookupPatchMeta(current, patch, lookupPatchMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewDefaultInstance(currentObj)
+	if err = json.Unmarshal(newCurrent, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// KeepCurrentObject leaves the current object as it is
+func KeepCurrentObject(_, currentObj runtime.Object, _ string) (runtime.Object, error) {
+	return currentObj, nil
+}
@@ -0,0 +1,130 @@
+package sdk
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var deploymentGVK = appsv1.SchemeGroupVersion.WithKind("Deployment")
+
+var _ = Describe("MergeStrategyRegistry", func() {
+	It("should keep current ConfigMap by default", func() {
+		desired := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm"}, Data: map[string]string{"key": "desired"}}
+		current := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm"}, Data: map[string]string{"key": "current"}}
+
+		merged, err := NewMergeStrategyRegistry().StrategyFor(corev1.SchemeGroupVersion.WithKind("ConfigMap"))(desired, current, lastsAppliedConfigurationAnnotation)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(merged.(*corev1.ConfigMap).Data).To(HaveKeyWithValue("key", "current"))
+	})
+
+	It("should revert foreign containers of Deployments by default", func() {
+		desired, current := createDeploymentsWithSidecar()
+
+		merged, err := NewMergeStrategyRegistry().StrategyFor(deploymentGVK)(desired, current, lastsAppliedConfigurationAnnotation)
+
+		Expect(err).ToNot(HaveOccurred())
+		containers := merged.(*appsv1.Deployment).Spec.Template.Spec.Containers
+		Expect(containers).To(HaveLen(1))
+		Expect(containers[0].Image).To(Equal("image:desired"))
+	})
+
+	It("should keep foreign list items of other built-in kinds by default", func() {
+		desired := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "svc"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+		}
+		Expect(SetLastAppliedConfiguration(desired, lastsAppliedConfigurationAnnotation)).To(Succeed())
+		current := desired.DeepCopy()
+		current.Spec.Ports = append(current.Spec.Ports, corev1.ServicePort{Name: "foreign", Port: 8080})
+
+		merged, err := NewMergeStrategyRegistry().StrategyFor(corev1.SchemeGroupVersion.WithKind("Service"))(desired, current, lastsAppliedConfigurationAnnotation)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(merged.(*corev1.Service).Spec.Ports).To(ConsistOf(
+			corev1.ServicePort{Name: "http", Port: 80},
+			corev1.ServicePort{Name: "foreign", Port: 8080},
+		))
+	})
+
+	It("should revert foreign list items of custom resources by default", func() {
+		gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Example"}
+		desired := &unstructured.Unstructured{}
+		desired.SetGroupVersionKind(gvk)
+		desired.SetName("example")
+		Expect(unstructured.SetNestedStringSlice(desired.Object, []string{"desired"}, "spec", "items")).To(Succeed())
+		Expect(SetLastAppliedConfiguration(desired, lastsAppliedConfigurationAnnotation)).To(Succeed())
+		current := desired.DeepCopy()
+		Expect(unstructured.SetNestedStringSlice(current.Object, []string{"desired", "foreign"}, "spec", "items")).To(Succeed())
+
+		merged, err := NewMergeStrategyRegistry().StrategyFor(gvk)(desired, current, lastsAppliedConfigurationAnnotation)
+
+		Expect(err).ToNot(HaveOccurred())
+		items, _, err := unstructured.NestedStringSlice(merged.(*unstructured.Unstructured).Object, "spec", "items")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(items).To(Equal([]string{"desired"}))
+	})
+
+	It("should use registered strategy", func() {
+		desired, current := createDeploymentsWithSidecar()
+		registry := NewMergeStrategyRegistry()
+		registry.Register(deploymentGVK, StrategicMergeObject)
+
+		merged, err := registry.StrategyFor(deploymentGVK)(desired, current, lastsAppliedConfigurationAnnotation)
+
+		Expect(err).ToNot(HaveOccurred())
+		containers := merged.(*appsv1.Deployment).Spec.Template.Spec.Containers
+		Expect(containers).To(HaveLen(2))
+		Expect(containers[0].Image).To(Equal("image:desired"))
+		Expect(containers[1].Name).To(Equal("sidecar"))
+	})
+})
+
+var _ = Describe("StrategicMergeObject", func() {
+	It("should remove list items dropped from the desired object and keep fields set by others", func() {
+		lastApplied := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "svc"},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Name: "http", Port: 80}, {Name: "metrics", Port: 8443}},
+			},
+		}
+		err := SetLastAppliedConfiguration(lastApplied, lastsAppliedConfigurationAnnotation)
+		Expect(err).ToNot(HaveOccurred())
+
+		current := lastApplied.DeepCopy()
+		current.Spec.Ports[0].NodePort = 30080
+		desired := lastApplied.DeepCopy()
+		desired.Spec.Ports = desired.Spec.Ports[:1]
+
+		merged, err := StrategicMergeObject(desired, current, lastsAppliedConfigurationAnnotation)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(merged.(*corev1.Service).Spec.Ports).To(Equal([]corev1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}}))
+	})
+})
+
+func createDeploymentsWithSidecar() (*appsv1.Deployment, *appsv1.Deployment) {
+	desired := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployment"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "main", Image: "image:current"}},
+				},
+			},
+		},
+	}
+	err := SetLastAppliedConfiguration(desired, lastsAppliedConfigurationAnnotation)
+	Expect(err).ToNot(HaveOccurred())
+
+	current := desired.DeepCopy()
+	current.Spec.Template.Spec.Containers = append(current.Spec.Template.Spec.Containers, corev1.Container{Name: "sidecar", Image: "sidecar"})
+
+	desired.Spec.Template.Spec.Containers[0].Image = "image:desired"
+	return desired, current
+}
@@ -0,0 +1,320 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReadinessChecker checks whether the object, as read from the cluster, is ready. For an object that is not ready
+// it returns a message explaining why
+type ReadinessChecker func(c client.Client, obj runtime.Object) (bool, string, error)
+
+// ReadinessCheckerRegistry keeps readiness checkers registered for object kinds
+type ReadinessCheckerRegistry struct {
+	checkers map[schema.GroupVersionKind]ReadinessChecker
+}
+
+// NewReadinessCheckerRegistry creates new ReadinessCheckerRegistry with the checkers of Deployments, DaemonSets,
+// StatefulSets, Jobs, CustomResourceDefinitions, APIServices and admission webhook configurations registered. The
+// Pods of unready Deployments, DaemonSets and StatefulSets are not inspected, see EnablePodInspection
+func NewReadinessCheckerRegistry() *ReadinessCheckerRegistry {
+	registry := &ReadinessCheckerRegistry{
+		checkers: make(map[schema.GroupVersionKind]ReadinessChecker),
+	}
+	registry.Register(appsv1.SchemeGroupVersion.WithKind("Deployment"), func(_ client.Client, obj runtime.Object) (bool, string, error) {
+		return checkDeploymentReadiness(nil, obj)
+	})
+	registry.Register(appsv1.SchemeGroupVersion.WithKind("DaemonSet"), func(_ client.Client, obj runtime.Object) (bool, string, error) {
+		return checkDaemonSetReadiness(nil, obj)
+	})
+	registry.Register(appsv1.SchemeGroupVersion.WithKind("StatefulSet"), func(_ client.Client, obj runtime.Object) (bool, string, error) {
+		return checkStatefulSetReadiness(nil, obj)
+	})
+	registry.Register(batchv1.SchemeGroupVersion.WithKind("Job"), CheckJobReadiness)
+	registry.Register(extv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"), CheckCustomResourceDefinitionReadiness)
+	registry.Register(schema.GroupVersionKind{Group: extv1.SchemeGroupVersion.Group, Version: "v1beta1", Kind: "CustomResourceDefinition"}, CheckCustomResourceDefinitionReadiness)
+	for _, version := range []string{"v1", "v1beta1"} {
+		registry.Register(schema.GroupVersionKind{Group: "apiregistration.k8s.io", Version: version, Kind: "APIService"}, CheckAPIServiceReadiness)
+	}
+	for _, gv := range []schema.GroupVersion{admissionregistrationv1.SchemeGroupVersion, admissionregistrationv1beta1.SchemeGroupVersion} {
+		registry.Register(gv.WithKind("ValidatingWebhookConfiguration"), CheckWebhookConfigurationReadiness)
+		registry.Register(gv.WithKind("MutatingWebhookConfiguration"), CheckWebhookConfigurationReadiness)
+	}
+	return registry
+}
+
+// Register registers readiness checker for given object kind, replacing the previously registered one
+func (r *ReadinessCheckerRegistry) Register(gvk schema.GroupVersionKind, checker ReadinessChecker) {
+	r.checkers[gvk] = checker
+}
+
+// EnablePodInspection registers the checkers of Deployments, DaemonSets and StatefulSets, which inspect the Pods of
+// unready workloads for the containers failing to start. Listing the Pods requires the list permission on pods;
+// listing them through a cached client also starts a Pod informer, which requires the watch permission on pods
+func (r *ReadinessCheckerRegistry) EnablePodInspection() {
+	r.Register(appsv1.SchemeGroupVersion.WithKind("Deployment"), CheckDeploymentReadiness)
+	r.Register(appsv1.SchemeGroupVersion.WithKind("DaemonSet"), CheckDaemonSetReadiness)
+	r.Register(appsv1.SchemeGroupVersion.WithKind("StatefulSet"), CheckStatefulSetReadiness)
+}
+
+// CheckerFor returns the readiness checker registered for given object kind
+func (r *ReadinessCheckerRegistry) CheckerFor(gvk schema.GroupVersionKind) (ReadinessChecker, bool) {
+	checker, ok := r.checkers[gvk]
+	return checker, ok
+}
+
+// CheckDeploymentReadiness checks whether all the replicas of a Deployment are ready. For an unready Deployment
+// its pods are inspected for the containers failing to start
+func CheckDeploymentReadiness(c client.Client, obj runtime.Object) (bool, string, error) {
+	return checkDeploymentReadiness(c, obj)
+}
+
+func checkDeploymentReadiness(podReader client.Reader, obj runtime.Object) (bool, string, error) {
+	deployment := &appsv1.Deployment{}
+	if err := toTyped(obj, deployment); err != nil {
+		return false, "", err
+	}
+
+	if !CheckDeploymentReady(deployment) {
+		message := fmt.Sprintf("%d of %d replicas ready", deployment.Status.ReadyReplicas, desiredReplicas(deployment.Spec.Replicas))
+		return podsUnreadyMessage(podReader, deployment.Namespace, deployment.Spec.Selector, message)
+	}
+	return true, "", nil
+}
+
+// CheckDaemonSetReadiness checks whether the DaemonSet pods are ready on all the nodes they are scheduled to. For an unready
+// DaemonSet its pods are inspected for the containers failing to start
+func CheckDaemonSetReadiness(c client.Client, obj runtime.Object) (bool, string, error) {
+	return checkDaemonSetReadiness(c, obj)
+}
+
+func checkDaemonSetReadiness(podReader client.Reader, obj runtime.Object) (bool, string, error) {
+	daemonSet := &appsv1.DaemonSet{}
+	if err := toTyped(obj, daemonSet); err != nil {
+		return false, "", err
+	}
+
+	status := daemonSet.Status
+	if status.NumberReady != status.DesiredNumberScheduled {
+		message := fmt.Sprintf("%d of %d scheduled pods ready", status.NumberReady, status.DesiredNumberScheduled)
+		return podsUnreadyMessage(podReader, daemonSet.Namespace, daemonSet.Spec.Selector, message)
+	}
+	return true, "", nil
+}
+
+// CheckStatefulSetReadiness checks whether all the replicas of a StatefulSet are ready. For an unready StatefulSet
+// its pods are inspected for the containers failing to start
+func CheckStatefulSetReadiness(c client.Client, obj runtime.Object) (bool, string, error) {
+	return checkStatefulSetReadiness(c, obj)
+}
+
+func checkStatefulSetReadiness(podReader client.Reader, obj runtime.Object) (bool, string, error) {
+	statefulSet := &appsv1.StatefulSet{}
+	if err := toTyped(obj, statefulSet); err != nil {
+		return false, "", err
+	}
+
+	replicas := desiredReplicas(statefulSet.Spec.Replicas)
+	if statefulSet.Status.ReadyReplicas != replicas {
+		message := fmt.Sprintf("%d of %d replicas ready", statefulSet.Status.ReadyReplicas, replicas)
+		return podsUnreadyMessage(podReader, statefulSet.Namespace, statefulSet.Spec.Selector, message)
+	}
+	return true, "", nil
+}
+
+// CheckJobReadiness checks whether a Job has completed
+func CheckJobReadiness(_ client.Client, obj runtime.Object) (bool, string, error) {
+	job := &batchv1.Job{}
+	if err := toTyped(obj, job); err != nil {
+		return false, "", err
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, "", nil
+		case batchv1.JobFailed:
+			return false, fmt.Sprintf("failed: %s", condition.Message), nil
+		}
+	}
+	return false, "not completed", nil
+}
+
+// CheckCustomResourceDefinitionReadiness checks whether a CustomResourceDefinition is established
+func CheckCustomResourceDefinitionReadiness(_ client.Client, obj runtime.Object) (bool, string, error) {
+	return checkStatusCondition(obj, "Established")
+}
+
+// CheckAPIServiceReadiness checks whether an APIService is available
+func CheckAPIServiceReadiness(_ client.Client, obj runtime.Object) (bool, string, error) {
+	return checkStatusCondition(obj, "Available")
+}
+
+// CheckWebhookConfigurationReadiness checks whether the Services of all the webhooks of a validating or mutating
+// webhook configuration have ready endpoints
+func CheckWebhookConfigurationReadiness(c client.Client, obj runtime.Object) (bool, string, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return false, "", err
+	}
+
+	webhooks, err := nestedSlice(content, "webhooks")
+	if err != nil {
+		return false, "", err
+	}
+
+	for _, webhook := range webhooks {
+		webhookContent, ok := webhook.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, found, err := unstructured.NestedString(webhookContent, "clientConfig", "service", "name")
+		if err != nil {
+			return false, "", err
+		}
+		if !found {
+			continue
+		}
+		namespace, _, err := unstructured.NestedString(webhookContent, "clientConfig", "service", "namespace")
+		if err != nil {
+			return false, "", err
+		}
+
+		key := client.ObjectKey{Namespace: namespace, Name: name}
+		endpoints := &corev1.Endpoints{}
+		if err = c.Get(context.TODO(), key, endpoints); err != nil {
+			if errors.IsNotFound(err) {
+				return false, fmt.Sprintf("service %s has no endpoints", key), nil
+			}
+			return false, "", err
+		}
+
+		if !hasReadyAddresses(endpoints) {
+			return false, fmt.Sprintf("service %s has no ready endpoints", key), nil
+		}
+	}
+
+	return true, "", nil
+}
+
+// podsUnreadyMessage returns not ready result with the message extended by the reason of the first container of the
+// selected pods, which is waiting to start because of a failure (i.e. image pull failure). The pods are inspected only
+// with podReader set; failures to list them are logged and the message is returned unchanged
+func podsUnreadyMessage(podReader client.Reader, namespace string, selector *metav1.LabelSelector, message string) (bool, string, error) {
+	if podReader == nil || selector == nil {
+		return false, message, nil
+	}
+	podSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		log.Error(err, "Unable to inspect pods", "namespace", namespace)
+		return false, message, nil
+	}
+
+	pods := &corev1.PodList{}
+	if err = podReader.List(context.TODO(), pods, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: podSelector}); err != nil {
+		log.Error(err, "Unable to inspect pods", "namespace", namespace)
+		return false, message, nil
+	}
+
+	for _, pod := range pods.Items {
+		for _, containerStatus := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			waiting := containerStatus.State.Waiting
+			if waiting == nil || waiting.Reason == "" || waiting.Reason == "ContainerCreating" || waiting.Reason == "PodInitializing" {
+				continue
+			}
+			message = fmt.Sprintf("%s, pod %s container %s is %s", message, pod.Name, containerStatus.Name, waiting.Reason)
+			if waiting.Message != "" {
+				message = fmt.Sprintf("%s: %s", message, waiting.Message)
+			}
+			return false, message, nil
+		}
+	}
+	return false, message, nil
+}
+
+func checkStatusCondition(obj runtime.Object, conditionType string) (bool, string, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return false, "", err
+	}
+
+	conditions, err := nestedSlice(content, "status", "conditions")
+	if err != nil {
+		return false, "", err
+	}
+
+	for _, condition := range conditions {
+		conditionContent, ok := condition.(map[string]interface{})
+		if !ok || conditionContent["type"] != conditionType {
+			continue
+		}
+		if strings.EqualFold(fmt.Sprint(conditionContent["status"]), string(corev1.ConditionTrue)) {
+			return true, "", nil
+		}
+		if message, ok := conditionContent["message"].(string); ok && message != "" {
+			return false, fmt.Sprintf("not %s: %s", strings.ToLower(conditionType), message), nil
+		}
+		break
+	}
+
+	return false, fmt.Sprintf("not %s", strings.ToLower(conditionType)), nil
+}
+
+// nestedSlice returns the slice at given path; missing and null fields are returned as an empty slice
+func nestedSlice(content map[string]interface{}, fields ...string) ([]interface{}, error) {
+	value, found, err := unstructured.NestedFieldNoCopy(content, fields...)
+	if err != nil || !found || value == nil {
+		return nil, err
+	}
+	slice, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%v is of the type %T, expected []interface{}", value, value)
+	}
+	return slice, nil
+}
+
+func hasReadyAddresses(endpoints *corev1.Endpoints) bool {
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// toTyped fills typed object from the obj, which can be either of the same type or unstructured
+func toTyped(obj runtime.Object, typed runtime.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed)
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(content, typed)
+}
@@ -0,0 +1,187 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ReadinessCheckerRegistry", func() {
+	It("should have built-in checkers registered", func() {
+		registry := NewReadinessCheckerRegistry()
+
+		_, ok := registry.CheckerFor(appsv1.SchemeGroupVersion.WithKind("StatefulSet"))
+		Expect(ok).To(BeTrue())
+		_, ok = registry.CheckerFor(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should use registered checker", func() {
+		registry := NewReadinessCheckerRegistry()
+		gvk := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+		registry.Register(gvk, CheckJobReadiness)
+
+		checker, ok := registry.CheckerFor(gvk)
+		Expect(ok).To(BeTrue())
+		ready, message, err := checker(nil, &batchv1.Job{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeFalse())
+		Expect(message).To(Equal("not completed"))
+	})
+})
+
+var _ = Describe("Readiness checkers", func() {
+	replicas := int32(2)
+
+	DescribeTable("should check readiness", func(checker ReadinessChecker, obj runtime.Object, expectedReady bool, expectedMessage string) {
+		ready, message, err := checker(nil, obj)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(Equal(expectedReady))
+		Expect(message).To(Equal(expectedMessage))
+	},
+		Entry("of ready DaemonSet", CheckDaemonSetReadiness,
+			&appsv1.DaemonSet{Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberReady: 2}}, true, ""),
+		Entry("of unready DaemonSet", CheckDaemonSetReadiness,
+			&appsv1.DaemonSet{Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberReady: 1}}, false, "1 of 2 scheduled pods ready"),
+		Entry("of ready StatefulSet", CheckStatefulSetReadiness,
+			&appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Replicas: &replicas}, Status: appsv1.StatefulSetStatus{ReadyReplicas: 2}}, true, ""),
+		Entry("of unready StatefulSet", CheckStatefulSetReadiness,
+			&appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Replicas: &replicas}}, false, "0 of 2 replicas ready"),
+		Entry("of completed Job", CheckJobReadiness,
+			&batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}}}, true, ""),
+		Entry("of failed Job", CheckJobReadiness,
+			&batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}}}}, false, "failed: BackoffLimitExceeded"),
+		Entry("of established CRD", CheckCustomResourceDefinitionReadiness,
+			&extv1.CustomResourceDefinition{Status: extv1.CustomResourceDefinitionStatus{Conditions: []extv1.CustomResourceDefinitionCondition{{Type: extv1.Established, Status: extv1.ConditionTrue}}}}, true, ""),
+		Entry("of not established CRD", CheckCustomResourceDefinitionReadiness,
+			&extv1.CustomResourceDefinition{}, false, "not established"),
+		Entry("of unavailable APIService", CheckAPIServiceReadiness,
+			createAPIService("False", "service unreachable"), false, "not available: service unreachable"),
+		Entry("of available APIService", CheckAPIServiceReadiness,
+			createAPIService("True", ""), true, ""),
+	)
+
+	It("should report containers failing to start", func() {
+		labels := map[string]string{"app": "test"}
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "deployment"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "deployment-1", Labels: labels},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "main",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}},
+				}},
+			},
+		}
+		client := fakeClient.NewFakeClientWithScheme(scheme.Scheme, pod)
+
+		ready, message, err := CheckDeploymentReadiness(client, deployment)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeFalse())
+		Expect(message).To(Equal("0 of 2 replicas ready, pod deployment-1 container main is ImagePullBackOff: Back-off pulling image"))
+	})
+
+	It("should ignore failures to inspect pods", func() {
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "deployment"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}}},
+		}
+		client := &forbiddenListClient{Client: fakeClient.NewFakeClientWithScheme(scheme.Scheme)}
+
+		ready, message, err := CheckDeploymentReadiness(client, deployment)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeFalse())
+		Expect(message).To(Equal("0 of 2 replicas ready"))
+	})
+
+	It("should not inspect pods with registered checkers unless enabled", func() {
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "deployment"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}}},
+		}
+		client := &forbiddenListClient{Client: fakeClient.NewFakeClientWithScheme(scheme.Scheme)}
+		registry := NewReadinessCheckerRegistry()
+		checker, _ := registry.CheckerFor(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+
+		_, _, err := checker(client, deployment)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.lists).To(BeZero())
+
+		registry.EnablePodInspection()
+		checker, _ = registry.CheckerFor(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+		_, _, err = checker(client, deployment)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.lists).To(Equal(1))
+	})
+
+	It("should check whether webhook services have ready endpoints", func() {
+		webhookConfiguration := &admissionregistrationv1.ValidatingWebhookConfiguration{
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+				Name: "validate.test",
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{Namespace: "ns", Name: "webhook"},
+				},
+			}},
+		}
+		endpoints := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "webhook"}}
+		client := fakeClient.NewFakeClientWithScheme(scheme.Scheme, endpoints)
+
+		ready, message, err := CheckWebhookConfigurationReadiness(client, webhookConfiguration)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeFalse())
+		Expect(message).To(Equal("service ns/webhook has no ready endpoints"))
+
+		endpoints.Subsets = []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}}
+		Expect(client.Update(context.TODO(), endpoints)).To(Succeed())
+
+		ready, _, err = CheckWebhookConfigurationReadiness(client, webhookConfiguration)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeTrue())
+	})
+})
+
+func createAPIService(status, message string) *unstructured.Unstructured {
+	apiService := &unstructured.Unstructured{}
+	apiService.SetAPIVersion("apiregistration.k8s.io/v1")
+	apiService.SetKind("APIService")
+	apiService.SetName("v1.test")
+	apiService.Object["status"] = map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Available", "status": status, "message": message},
+		},
+	}
+	return apiService
+}
+
+// forbiddenListClient counts the lists, which are all forbidden
+type forbiddenListClient struct {
+	client.Client
+	lists int
+}
+
+func (c *forbiddenListClient) List(_ context.Context, _ runtime.Object, _ ...client.ListOption) error {
+	c.lists++
+	return errors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", fmt.Errorf("denied"))
+}
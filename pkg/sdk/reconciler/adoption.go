@@ -0,0 +1,178 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
+	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// OrphanPolicy defines how the reconciler handles desired resources, which exist in the cluster before the CR is
+// created, i.e. after restore from backup or after migration from another installation method
+type OrphanPolicy string
+
+const (
+	// OrphanPolicyWait waits until the orphaned resources are removed
+	OrphanPolicyWait OrphanPolicy = "Wait"
+	// OrphanPolicyAdopt makes the CR the owner of the orphaned resources
+	OrphanPolicyAdopt OrphanPolicy = "Adopt"
+	// OrphanPolicyFail moves the CR to the Error phase until the orphaned resources are removed
+	OrphanPolicyFail OrphanPolicy = "Fail"
+)
+
+const (
+	// OrphanResourcesReason is the reason of the condition reporting orphaned resources refused by the orphan policy
+	OrphanResourcesReason = "OrphanResources"
+	// ForeignOwnedResourcesReason is the reason of the condition reporting desired resources owned by another owner
+	ForeignOwnedResourcesReason = "ResourcesOwnedByOthers"
+	// WaitingForOrphansReason is the reason of the condition reporting orphaned resources the CR waits for
+	WaitingForOrphansReason = "WaitingForOrphans"
+)
+
+// orphanRequeueInterval is the interval of checking whether the orphaned resources blocking the CR were removed
+const orphanRequeueInterval = time.Minute
+
+// reconcileOrphans applies the orphan policy to the desired resources existing in the cluster. Returned result stops
+// the creation of the CR. Resources owned by another owner are never adopted
+func (r *Reconciler) reconcileOrphans(logger logr.Logger, cr controllerutil.Object, orphans []runtime.Object, operatorVersion string) (*reconcile.Result, error) {
+	crGvk, err := apiutil.GVKForObject(cr, r.scheme)
+	if err != nil {
+		return &reconcile.Result{}, err
+	}
+
+	var orphanKeys, foreignKeys []string
+	for _, orphan := range orphans {
+		key, err := r.resourceKey(orphan)
+		if err != nil {
+			return &reconcile.Result{}, err
+		}
+		orphanKeys = append(orphanKeys, key.String())
+		if r.ownedByOther(cr, crGvk, orphan.(metav1.Object)) {
+			foreignKeys = append(foreignKeys, key.String())
+		}
+	}
+
+	if len(foreignKeys) > 0 {
+		message := fmt.Sprintf("Resources controlled by other owners: %s", strings.Join(foreignKeys, ", "))
+		return r.failOrphans(logger, cr, ForeignOwnedResourcesReason, message)
+	}
+
+	switch r.orphanPolicy {
+	case OrphanPolicyAdopt:
+		for _, orphan := range orphans {
+			if err := r.adoptResource(logger, cr, orphan, operatorVersion); err != nil {
+				return &reconcile.Result{}, err
+			}
+		}
+		return nil, nil
+	case OrphanPolicyFail:
+		message := fmt.Sprintf("Resources already exist: %s", strings.Join(orphanKeys, ", "))
+		return r.failOrphans(logger, cr, OrphanResourcesReason, message)
+	default:
+		message := fmt.Sprintf("Waiting for resources to be removed: %s", strings.Join(orphanKeys, ", "))
+		return r.waitForOrphans(logger, cr, message)
+	}
+}
+
+// waitForOrphans reports the orphaned resources blocking the creation of the CR in the Progressing condition; the
+// orphans are checked again after orphanRequeueInterval. The status is updated only when the orphans change
+func (r *Reconciler) waitForOrphans(logger logr.Logger, cr controllerutil.Object, message string) (*reconcile.Result, error) {
+	logger.Info("Waiting for orphaned resources to be removed", "reason", message)
+	status := r.status(cr)
+	if waitingForOrphans(status) {
+		progressing := conditions.FindStatusCondition(status.Conditions, conditions.ConditionProgressing)
+		if progressing.Message == message {
+			return &reconcile.Result{RequeueAfter: orphanRequeueInterval}, nil
+		}
+	}
+	r.recordEvent(cr, corev1.EventTypeNormal, WaitingForOrphansReason, "%s", message)
+	sdk.MarkCrDeploying(status, WaitingForOrphansReason, message)
+	if err := r.CrUpdate(status.Phase, cr); err != nil {
+		return &reconcile.Result{}, err
+	}
+	return &reconcile.Result{RequeueAfter: orphanRequeueInterval}, nil
+}
+
+// waitingForOrphans checks whether the creation of the CR is waiting for the orphaned resources to be removed
+func waitingForOrphans(status *sdkapi.Status) bool {
+	if status.Phase != sdkapi.PhaseEmpty {
+		return false
+	}
+	progressing := conditions.FindStatusCondition(status.Conditions, conditions.ConditionProgressing)
+	return progressing != nil && progressing.Reason == WaitingForOrphansReason
+}
+
+// ownedByOther checks whether the resource is controlled by, or annotated with, another owner than the CR. References
+// to a former instance of the CR, i.e. restored from backup, do not make the resource foreign
+func (r *Reconciler) ownedByOther(cr controllerutil.Object, crGvk schema.GroupVersionKind, obj metav1.Object) bool {
+	if ref := metav1.GetControllerOf(obj); ref != nil {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil || gv.Group != crGvk.Group || ref.Kind != crGvk.Kind || ref.Name != cr.GetName() {
+			return true
+		}
+	}
+	if r.ownerAnnotation == "" {
+		return false
+	}
+	owner, ok := obj.GetAnnotations()[r.ownerAnnotation]
+	return ok && owner != ownerReference(cr)
+}
+
+// adoptResource makes the CR the owner of the orphaned resource and labels it with the operator version when not
+// labelled yet. The content of the resource is reconciled by the following update
+func (r *Reconciler) adoptResource(logger logr.Logger, cr controllerutil.Object, obj runtime.Object, operatorVersion string) error {
+	if r.isOptedOut(obj) {
+		return nil
+	}
+
+	metaObj := obj.(metav1.Object)
+	// the controller reference of a former instance of the CR is replaced
+	var refs []metav1.OwnerReference
+	for _, ref := range metaObj.GetOwnerReferences() {
+		if ref.Controller == nil || !*ref.Controller {
+			refs = append(refs, ref)
+		}
+	}
+	metaObj.SetOwnerReferences(refs)
+	if err := r.setOwner(cr, metaObj); err != nil {
+		return err
+	}
+	if _, ok := metaObj.GetLabels()[r.createVersionLabel]; !ok {
+		sdk.SetLabel(r.createVersionLabel, operatorVersion, metaObj)
+	}
+
+	if err := r.client.Update(context.TODO(), obj); err != nil {
+		return err
+	}
+	logger.Info("Resource adopted",
+		"namespace", metaObj.GetNamespace(),
+		"name", metaObj.GetName(),
+		"type", fmt.Sprintf("%T", metaObj))
+	r.recordResourceEvent(cr, ResourceAdoptedReason, "Adopted", obj)
+	r.countResourceOperation(obj, resourceUpdated)
+	return nil
+}
+
+// failOrphans moves the CR to the Error phase without touching the orphaned resources; the orphans are checked again
+// after orphanRequeueInterval
+func (r *Reconciler) failOrphans(logger logr.Logger, cr controllerutil.Object, reason, message string) (*reconcile.Result, error) {
+	logger.Info("Unable to create CR", "reason", message)
+	r.recordEvent(cr, corev1.EventTypeWarning, reason, "%s", message)
+	sdk.MarkCrFailed(r.status(cr), reason, message)
+	if err := r.CrUpdate(sdkapi.PhaseError, cr); err != nil {
+		return &reconcile.Result{}, err
+	}
+	return &reconcile.Result{RequeueAfter: orphanRequeueInterval}, nil
+}
@@ -0,0 +1,172 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/callbacks"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// serverSideApply reconciles single desired resource using server-side apply with the configured field manager.
+func (r *Reconciler) serverSideApply(logger logr.Logger, cr controllerutil.Object, desiredRuntimeObj runtime.Object, operatorVersion string) resourceResult {
+	desiredMetaObj := desiredRuntimeObj.(metav1.Object)
+
+	gvk, err := apiutil.GVKForObject(desiredRuntimeObj, r.scheme)
+	if err != nil {
+		return resourceResult{err: err}
+	}
+
+	currentRuntimeObj := sdk.NewDefaultInstance(desiredRuntimeObj)
+	key := client.ObjectKey{
+		Namespace: desiredMetaObj.GetNamespace(),
+		Name:      desiredMetaObj.GetName(),
+	}
+	if err = r.getResource(key, currentRuntimeObj); err != nil {
+		if !errors.IsNotFound(err) {
+			return resourceResult{err: err}
+		}
+
+		sdk.SetLabel(r.createVersionLabel, operatorVersion, desiredMetaObj)
+		if err = r.setOwner(cr, desiredMetaObj); err != nil {
+			return resourceResult{err: err}
+		}
+
+		// PRE_CREATE callback
+		if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePreCreate, desiredRuntimeObj, nil); err != nil {
+			return resourceResult{err: err}
+		}
+
+		if err = r.apply(desiredRuntimeObj, gvk); err != nil {
+			logger.Error(err, "")
+			return resourceResult{writeErr: err}
+		}
+
+		// POST_CREATE callback
+		if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostCreate, desiredRuntimeObj, nil); err != nil {
+			return resourceResult{err: err}
+		}
+
+		logger.Info("Resource created",
+			"namespace", desiredMetaObj.GetNamespace(),
+			"name", desiredMetaObj.GetName(),
+			"type", fmt.Sprintf("%T", desiredMetaObj))
+		r.recordResourceEvent(cr, ResourceCreatedReason, "Created", desiredRuntimeObj)
+		r.countResourceOperation(desiredRuntimeObj, resourceCreated)
+		return resourceResult{version: operatorVersion}
+	}
+
+	if r.isOptedOut(currentRuntimeObj) {
+		logger.V(3).Info("Resource opted out of reconciliation",
+			"namespace", desiredMetaObj.GetNamespace(),
+			"name", desiredMetaObj.GetName(),
+			"type", fmt.Sprintf("%T", desiredMetaObj))
+		return resourceResult{optedOut: true}
+	}
+
+	// POST_READ callback
+	if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostRead, desiredRuntimeObj, currentRuntimeObj); err != nil {
+		return resourceResult{err: err}
+	}
+
+	currentComparable, appliedComparable, err := r.dryRunApply(cr, desiredRuntimeObj, currentRuntimeObj, gvk)
+	if err != nil {
+		return resourceResult{err: err}
+	}
+
+	if reflect.DeepEqual(currentComparable, appliedComparable) {
+		logger.V(3).Info("Resource unchanged",
+			"namespace", desiredMetaObj.GetNamespace(),
+			"name", desiredMetaObj.GetName(),
+			"type", fmt.Sprintf("%T", desiredMetaObj))
+		return resourceResult{version: operatorVersion}
+	}
+
+	sdk.LogJSONDiff(logger, currentComparable, appliedComparable)
+	sdk.SetLabel(r.updateVersionLabel, operatorVersion, desiredMetaObj)
+
+	// PRE_UPDATE callback
+	if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePreUpdate, desiredRuntimeObj, currentRuntimeObj); err != nil {
+		return resourceResult{err: err}
+	}
+
+	if err = r.apply(desiredRuntimeObj, gvk); err != nil {
+		logger.Error(err, "")
+		return resourceResult{writeErr: err, version: r.writtenVersion(currentRuntimeObj.(metav1.Object))}
+	}
+
+	// POST_UPDATE callback
+	if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostUpdate, desiredRuntimeObj, nil); err != nil {
+		return resourceResult{err: err}
+	}
+
+	logger.Info("Resource updated",
+		"namespace", desiredMetaObj.GetNamespace(),
+		"name", desiredMetaObj.GetName(),
+		"type", fmt.Sprintf("%T", desiredMetaObj))
+	r.recordResourceEvent(cr, ResourceUpdatedReason, "Updated", desiredRuntimeObj)
+	r.countResourceOperation(desiredRuntimeObj, resourceUpdated)
+	return resourceResult{version: operatorVersion}
+}
+
+// dryRunApply applies the desired object in dry-run mode and returns the current and the applied object stripped of the
+// fields maintained by the API server
+func (r *Reconciler) dryRunApply(cr controllerutil.Object, desiredRuntimeObj, currentRuntimeObj runtime.Object, gvk schema.GroupVersionKind) (runtime.Object, runtime.Object, error) {
+	// fields not listed in the applied configuration are released by our field manager, so the version labels
+	// and the owner have to be sent on every apply
+	desiredMetaObj := desiredRuntimeObj.(metav1.Object)
+	currentMetaObj := currentRuntimeObj.(metav1.Object)
+	for _, label := range []string{r.createVersionLabel, r.updateVersionLabel} {
+		if value, ok := currentMetaObj.GetLabels()[label]; ok {
+			sdk.SetLabel(label, value, desiredMetaObj)
+		}
+	}
+	if err := r.setOwner(cr, desiredMetaObj); err != nil {
+		return nil, nil, err
+	}
+
+	// dry-run shows whether applying the desired state would change anything
+	appliedRuntimeObj := desiredRuntimeObj.DeepCopyObject()
+	appliedRuntimeObj.GetObjectKind().SetGroupVersionKind(gvk)
+	if err := r.client.Patch(context.TODO(), appliedRuntimeObj, client.Apply, client.FieldOwner(r.fieldManager), client.ForceOwnership, client.DryRunAll); err != nil {
+		return nil, nil, err
+	}
+
+	currentComparable, err := comparableObject(currentRuntimeObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	appliedComparable, err := comparableObject(appliedRuntimeObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return currentComparable, appliedComparable, nil
+}
+
+func (r *Reconciler) apply(desiredRuntimeObj runtime.Object, gvk schema.GroupVersionKind) error {
+	obj := desiredRuntimeObj.DeepCopyObject()
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return r.client.Patch(context.TODO(), obj, client.Apply, client.FieldOwner(r.fieldManager), client.ForceOwnership)
+}
+
+// comparableObject returns copy of the object without the fields that are maintained by the API server
+func comparableObject(obj runtime.Object) (runtime.Object, error) {
+	result, err := sdk.StripStatusFromObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	result.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+	metaObj := result.(metav1.Object)
+	metaObj.SetManagedFields(nil)
+	metaObj.SetResourceVersion("")
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package reconciler
+
+import (
+	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// statusBatch collects the status changes of the CR made during single reconciliation, so that they are written once
+type statusBatch struct {
+	// original is the CR as last read from or written to the cluster
+	original      runtime.Object
+	previousPhase sdkapi.Phase
+	dirty         bool
+}
+
+// beginStatusBatch makes CrUpdate keep the status changes of the CR in memory until flushCrStatus or endStatusBatch
+func (r *Reconciler) beginStatusBatch(cr runtime.Object) {
+	r.statusBatches.Store(cr, &statusBatch{
+		original:      cr.DeepCopyObject(),
+		previousPhase: r.status(cr).Phase,
+	})
+}
+
+// endStatusBatch writes the pending status changes of the CR and stops collecting them
+func (r *Reconciler) endStatusBatch(cr runtime.Object) error {
+	defer r.statusBatches.Delete(cr)
+	return r.flushCrStatus(cr)
+}
+
+func (r *Reconciler) statusBatch(cr runtime.Object) (*statusBatch, bool) {
+	batch, ok := r.statusBatches.Load(cr)
+	if !ok {
+		return nil, false
+	}
+	return batch.(*statusBatch), true
+}
+
+// flushCrStatus writes the pending status changes of the CR, if any, against the originally read CR
+func (r *Reconciler) flushCrStatus(cr runtime.Object) error {
+	batch, ok := r.statusBatch(cr)
+	if !ok || !batch.dirty {
+		return nil
+	}
+
+	if err := r.patchCrStatus(cr, batch.original); err != nil {
+		return err
+	}
+	phase := r.status(cr).Phase
+	r.recordPhaseEvent(cr, batch.previousPhase, phase)
+	r.recordStatusMetrics(cr)
+	batch.previousPhase = phase
+	batch.dirty = false
+	return nil
+}
+
+// updateStatusBatchOriginal records the CR written to the cluster as the original object of the status batch
+func (r *Reconciler) updateStatusBatchOriginal(cr, written runtime.Object) {
+	if batch, ok := r.statusBatch(cr); ok {
+		batch.original = written
+	}
+}
@@ -1,13 +1,19 @@
 package reconciler
 
 import (
+	"fmt"
 	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 
+	"github.com/blang/semver"
 	"github.com/go-logr/logr"
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -25,6 +31,13 @@ func NewReconciler(crManager CrManager, log logr.Logger, client client.Client, c
 		lastAppliedConfigAnnotation:   lastAppliedConfigAnnotation,
 		perishablesSyncInterval:       perishablesSyncInterval,
 		finalizerName:                 finalizerName,
+		mergeStrategies:               sdk.NewMergeStrategyRegistry(),
+		readinessCheckers:             sdk.NewReadinessCheckerRegistry(),
+		defaultDowngradePolicy:        DowngradePolicyRefuse,
+		orphanPolicy:                  OrphanPolicyWait,
+		upgradeHistoryLimit:           defaultUpgradeHistoryLimit,
+		workers:                       1,
+		uncachedKinds:                 make(map[schema.GroupVersionKind]bool),
 		syncPerishables:               syncPerishables,
 		updateControllerConfiguration: updateControllerConfiguration,
 		checkSanity:                   checkSanity,
@@ -33,6 +46,30 @@ func NewReconciler(crManager CrManager, log logr.Logger, client client.Client, c
 	}
 }
 
+// Build returns the configured Reconciler, or the first error of the builder options, i.e. when the kind of an object
+// passed to WithMergeStrategy, WithReadinessChecker or WithUncachedReads is unknown to the scheme, or when the version
+// range passed to WithMigration is invalid. Reconcile fails with the same error
+func (r *Reconciler) Build() (*Reconciler, error) {
+	return r, r.buildErr
+}
+
+// optionGvk resolves the kind of the object passed to a builder option; the error is recorded to be returned by Build
+func (r *Reconciler) optionGvk(obj runtime.Object) (schema.GroupVersionKind, bool) {
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		r.recordBuildErr(err)
+		return gvk, false
+	}
+	return gvk, true
+}
+
+// recordBuildErr records the error of a builder option to be returned by Build, unless an earlier one is recorded
+func (r *Reconciler) recordBuildErr(err error) {
+	if r.buildErr == nil {
+		r.buildErr = err
+	}
+}
+
 // WithController sets controller
 func (r *Reconciler) WithController(controller controller.Controller) *Reconciler {
 	r.controller = controller
@@ -78,6 +115,208 @@ func (r *Reconciler) WithPreCreateHook(preCreate PreCreateHook) *Reconciler {
 	return r
 }
 
+// WithServerSideApply makes the reconciler send managed resources as server-side apply patches owned by given field manager
+// instead of merging them with the last applied configuration; empty fieldManager restores the default behavior
+func (r *Reconciler) WithServerSideApply(fieldManager string) *Reconciler {
+	r.fieldManager = fieldManager
+	return r
+}
+
+// WithMergeStrategy sets MergeStrategy used to update existing resources of the kind of given object
+func (r *Reconciler) WithMergeStrategy(obj runtime.Object, strategy sdk.MergeStrategy) *Reconciler {
+	if strategy == nil {
+		panic("Merge strategy mustn't be nil")
+	}
+	if gvk, ok := r.optionGvk(obj); ok {
+		r.mergeStrategies.Register(gvk, strategy)
+	}
+	return r
+}
+
+// WithRolloutWaves makes the reconciler deploy managed resources in waves, ordered by the integer value of given annotation.
+// Resources of a wave are applied only after all resources of the previous waves exist and are ready
+func (r *Reconciler) WithRolloutWaves(annotation string) *Reconciler {
+	r.rolloutWaveAnnotation = annotation
+	return r
+}
+
+// WithReadinessChecker sets ReadinessChecker used to check readiness of the resources of the kind of given object
+func (r *Reconciler) WithReadinessChecker(obj runtime.Object, checker sdk.ReadinessChecker) *Reconciler {
+	if checker == nil {
+		panic("Readiness checker mustn't be nil")
+	}
+	if gvk, ok := r.optionGvk(obj); ok {
+		r.readinessCheckers.Register(gvk, checker)
+	}
+	return r
+}
+
+// WithPodInspection makes the readiness checks of Deployments, DaemonSets and StatefulSets report the containers of
+// their Pods failing to start. Listing the Pods requires the list permission on pods; without the uncached client set by
+// WithUncachedClient, the Pods are listed through a cluster-wide Pod informer, which requires the watch permission too
+func (r *Reconciler) WithPodInspection() *Reconciler {
+	r.readinessCheckers.EnablePodInspection()
+	return r
+}
+
+// WithEventRecorder sets EventRecorder used to record events about the reconciliation on the CR
+func (r *Reconciler) WithEventRecorder(recorder record.EventRecorder) *Reconciler {
+	r.recorder = recorder
+	return r
+}
+
+// WithDowngradePolicy sets the policy applied when the operator version is lower than the observed one
+func (r *Reconciler) WithDowngradePolicy(policy DowngradePolicy) *Reconciler {
+	r.defaultDowngradePolicy = policy
+	return r
+}
+
+// WithDowngradePolicyAnnotation sets the CR annotation, which value overrides the downgrade policy of the reconciler
+func (r *Reconciler) WithDowngradePolicyAnnotation(annotation string) *Reconciler {
+	r.downgradePolicyAnnotation = annotation
+	return r
+}
+
+// WithUpgradeGraph sets UpgradeGraph constraining the operator upgrades
+func (r *Reconciler) WithUpgradeGraph(graph *UpgradeGraph) *Reconciler {
+	r.upgradeGraph = graph
+	return r
+}
+
+// WithMigration registers Migration executed on upgrades from a version outside of the semver range to a version in it,
+// i.e. ">=1.5.0". The migrations are executed in the order of registration, after the resources are upgraded. An
+// invalid range is returned by Build
+func (r *Reconciler) WithMigration(name string, versionRange string, migrate Migration) *Reconciler {
+	if name == "" || migrate == nil {
+		panic("Migration must have a name and mustn't be nil")
+	}
+	for _, m := range r.migrations {
+		if m.name == name {
+			panic(fmt.Sprintf("Migration %s is already registered", name))
+		}
+	}
+	parsedRange, err := semver.ParseRange(versionRange)
+	if err != nil {
+		r.recordBuildErr(fmt.Errorf("invalid version range of migration %s: %v", name, err))
+		return r
+	}
+	r.migrations = append(r.migrations, migration{name: name, versionRange: parsedRange, migrate: migrate})
+	return r
+}
+
+// WithUpgradeHistoryLimit sets the number of the finished upgrades kept in the CR status
+func (r *Reconciler) WithUpgradeHistoryLimit(limit int) *Reconciler {
+	r.upgradeHistoryLimit = limit
+	return r
+}
+
+// WithUpgradeTimeout makes the reconciler mark the upgrades not completed within the timeout failed. The CR is not
+// reconciled after a failed upgrade until the operator version changes
+func (r *Reconciler) WithUpgradeTimeout(timeout time.Duration) *Reconciler {
+	r.upgradeTimeout = timeout
+	return r
+}
+
+// WithUpgradeRollback makes the reconciler roll the managed resources back to the previous version when an upgrade
+// times out. The last applied configuration of the previous version is kept in given annotation during the upgrade.
+// Resources created during the upgrade are removed. Server-side applied resources are not rolled back
+func (r *Reconciler) WithUpgradeRollback(annotation string) *Reconciler {
+	r.rollbackAnnotation = annotation
+	return r
+}
+
+// WithPauseAnnotation makes the reconciler stop writing the managed resources of the CR annotated with given annotation
+// set to "true". The status conditions of the paused CR are still refreshed and the Paused condition is set
+func (r *Reconciler) WithPauseAnnotation(annotation string) *Reconciler {
+	r.pauseAnnotation = annotation
+	return r
+}
+
+// WithOptOutAnnotation makes the reconciler leave the managed resources annotated with given annotation set to "true"
+// unchanged, i.e. to keep a hot-patched Deployment. Such resources are neither updated nor deleted
+func (r *Reconciler) WithOptOutAnnotation(annotation string) *Reconciler {
+	r.optOutAnnotation = annotation
+	return r
+}
+
+// WithConcurrency sets the number of the resources of a rollout wave reconciled concurrently. The callbacks of single
+// resource are invoked in order, but callbacks of different resources can be invoked concurrently
+func (r *Reconciler) WithConcurrency(workers int) *Reconciler {
+	if workers < 1 {
+		panic("Number of workers must be positive")
+	}
+	r.workers = workers
+	return r
+}
+
+// WithUncachedClient sets the client reading the managed resources selected by the read policy, which is set by
+// WithUncachedReads, WithUncachedClusterScopedReads and WithUncachedFallback. The readiness checkers use it too, so the
+// Pods inspected by WithPodInspection are listed without a Pod informer
+func (r *Reconciler) WithUncachedClient(uncachedClient client.Client) *Reconciler {
+	r.uncachedClient = uncachedClient
+	return r
+}
+
+// WithUncachedReads makes the reconciler read the managed resources of the kind of given object with the uncached client
+func (r *Reconciler) WithUncachedReads(obj runtime.Object) *Reconciler {
+	if gvk, ok := r.optionGvk(obj); ok {
+		r.uncachedKinds[gvk] = true
+	}
+	return r
+}
+
+// WithUncachedClusterScopedReads makes the reconciler read the cluster-scoped managed resources with the uncached client
+func (r *Reconciler) WithUncachedClusterScopedReads() *Reconciler {
+	r.uncachedClusterScoped = true
+	return r
+}
+
+// WithUncachedFallback makes the reconciler read the managed resources not found in the cache with the uncached client,
+// i.e. when the cache is restricted to selected namespaces or labels. Each read of a missing resource reaches the API
+// server then
+func (r *Reconciler) WithUncachedFallback() *Reconciler {
+	r.uncachedFallback = true
+	return r
+}
+
+// WithOwnerAnnotation sets the annotation identifying the namespaced CR on the managed resources it cannot be the
+// controller owner of, i.e. cluster-scoped resources and resources in other namespaces. Changes of such resources are
+// mapped to the CR by the annotation, and the resources are removed by the reconciler when unused or when the CR is
+// deleted. The annotation has to be set before the managed resources are watched
+func (r *Reconciler) WithOwnerAnnotation(annotation string) *Reconciler {
+	r.ownerAnnotation = annotation
+	return r
+}
+
+// WithSingleton makes the reconciler reconcile only one CR: the CR with given name or, when the name is empty, the
+// oldest CR. Other CRs are moved to the Error phase without touching the managed resources
+func (r *Reconciler) WithSingleton(name string) *Reconciler {
+	r.singleton = true
+	r.singletonName = name
+	return r
+}
+
+// WithOrphanPolicy sets the policy applied when the desired resources exist in the cluster before the CR is created.
+// Resources controlled by another owner are reported in the CR conditions regardless of the policy
+func (r *Reconciler) WithOrphanPolicy(policy OrphanPolicy) *Reconciler {
+	r.orphanPolicy = policy
+	return r
+}
+
+// WithPruning makes the reconciler remove the resources owned by the CR, which are no longer desired, every time all
+// the desired resources are rolled out, not only on upgrades. PlanPruning reports the resources to be removed
+func (r *Reconciler) WithPruning() *Reconciler {
+	r.pruning = true
+	return r
+}
+
+// WithInventory makes the reconciler record the applied resources in the inventory in the CR status. The resources to be
+// pruned or removed with the CR are then read from the inventory instead of listing the dependant resource types
+func (r *Reconciler) WithInventory() *Reconciler {
+	r.inventory = true
+	return r
+}
+
 func preCreate(_ controllerutil.Object) error {
 	return nil
 }
@@ -0,0 +1,38 @@
+package reconciler
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DowngradePolicy defines how the reconciler handles operator version lower than the observed one
+type DowngradePolicy string
+
+const (
+	// DowngradePolicyRefuse refuses to reconcile the CR after a downgrade
+	DowngradePolicyRefuse DowngradePolicy = "Refuse"
+	// DowngradePolicyAllow reconciles the CR to the lower version following the upgrade path
+	DowngradePolicyAllow DowngradePolicy = "Allow"
+)
+
+// downgradePolicy returns the downgrade policy of the CR: the value of the downgrade policy annotation, when set,
+// or the policy of the reconciler
+func (r *Reconciler) downgradePolicy(cr runtime.Object) (DowngradePolicy, error) {
+	if r.downgradePolicyAnnotation == "" {
+		return r.defaultDowngradePolicy, nil
+	}
+
+	value, ok := cr.(metav1.Object).GetAnnotations()[r.downgradePolicyAnnotation]
+	if !ok {
+		return r.defaultDowngradePolicy, nil
+	}
+
+	switch policy := DowngradePolicy(value); policy {
+	case DowngradePolicyRefuse, DowngradePolicyAllow:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid downgrade policy %q in annotation %s", value, r.downgradePolicyAnnotation)
+	}
+}
@@ -0,0 +1,40 @@
+package reconciler
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// maxResourceWriteAttempts is the number of attempts to write a managed resource failing on conflicts
+const maxResourceWriteAttempts = 5
+
+// ResourceError describes failed write of a managed resource
+type ResourceError struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	Err              error
+}
+
+func (e *ResourceError) Error() string {
+	key := resourceKey{gvk: e.GroupVersionKind, namespace: e.Namespace, name: e.Name}
+	return fmt.Sprintf("%s: %v", key, e.Err)
+}
+
+// Unwrap returns the cause of the failed write
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
+
+// ReconcileErrors aggregates the failed writes of the managed resources during single reconciliation
+type ReconcileErrors []*ResourceError
+
+func (e ReconcileErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("reconcile encountered %d errors: %s", len(e), strings.Join(messages, "; "))
+}
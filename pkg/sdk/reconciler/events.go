@@ -0,0 +1,63 @@
+package reconciler
+
+import (
+	"fmt"
+
+	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Reasons of the events recorded on the CR
+const (
+	PhaseChangedReason     = "PhaseChanged"
+	ResourceCreatedReason  = "ResourceCreated"
+	ResourceUpdatedReason  = "ResourceUpdated"
+	ResourceDeletedReason  = "ResourceDeleted"
+	ResourceAdoptedReason  = "ResourceAdopted"
+	UpgradeStartedReason   = "UpgradeStarted"
+	UpgradeCompletedReason = "UpgradeCompleted"
+	UpgradeFailedReason    = "UpgradeFailed"
+	DowngradeRefusedReason = "DowngradeRefused"
+	DowngradeStartedReason = "DowngradeStarted"
+)
+
+func (k resourceKey) String() string {
+	if k.namespace == "" {
+		return fmt.Sprintf("%s %s", k.gvk.Kind, k.name)
+	}
+	return fmt.Sprintf("%s %s/%s", k.gvk.Kind, k.namespace, k.name)
+}
+
+// recordEvent records event on the CR when the event recorder is configured
+func (r *Reconciler) recordEvent(cr runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.recorder == nil {
+		return
+	}
+	r.recorder.Eventf(cr, eventType, reason, messageFmt, args...)
+}
+
+// recordResourceEvent records event about the managed resource on the CR
+func (r *Reconciler) recordResourceEvent(cr runtime.Object, reason, action string, obj runtime.Object) {
+	if r.recorder == nil {
+		return
+	}
+	key, err := r.resourceKey(obj)
+	if err != nil {
+		r.log.Error(err, "Unable to record event", "reason", reason)
+		return
+	}
+	r.recordEvent(cr, corev1.EventTypeNormal, reason, "%s %s", action, key)
+}
+
+// recordPhaseEvent records the transition of the CR to the new phase
+func (r *Reconciler) recordPhaseEvent(cr runtime.Object, previousPhase, phase sdkapi.Phase) {
+	if previousPhase == phase {
+		return
+	}
+	eventType := corev1.EventTypeNormal
+	if phase == sdkapi.PhaseError {
+		eventType = corev1.EventTypeWarning
+	}
+	r.recordEvent(cr, eventType, PhaseChangedReason, "Phase changed from %q to %q", previousPhase, phase)
+}
@@ -0,0 +1,145 @@
+package reconciler
+
+import (
+	"github.com/go-logr/logr"
+	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// usesInventory checks whether the managed resources of the CR are discovered from the inventory in the CR status.
+// CRs reconciled before the inventory was enabled are discovered by listing until the inventory is recorded
+func (r *Reconciler) usesInventory(cr runtime.Object) bool {
+	return r.inventory && r.status(cr).InventoryInitialized
+}
+
+func inventoryKey(resource sdkapi.ManagedResource) resourceKey {
+	return resourceKey{
+		gvk:       schema.GroupVersionKind{Group: resource.Group, Version: resource.Version, Kind: resource.Kind},
+		namespace: resource.Namespace,
+		name:      resource.Name,
+	}
+}
+
+func managedResource(key resourceKey, operatorVersion string) sdkapi.ManagedResource {
+	return sdkapi.ManagedResource{
+		Group:           key.gvk.Group,
+		Version:         key.gvk.Version,
+		Kind:            key.gvk.Kind,
+		Namespace:       key.namespace,
+		Name:            key.name,
+		OperatorVersion: operatorVersion,
+	}
+}
+
+// updateInventory records the resources applied by given operator version in the inventory of the CR. The first
+// inventory is seeded with the listed resources owned by the CR, which could have been created by previous versions
+func (r *Reconciler) updateInventory(cr controllerutil.Object, keys []resourceKey, operatorVersion string) error {
+	if !r.inventory {
+		return nil
+	}
+	status := r.status(cr)
+	inventory := append([]sdkapi.ManagedResource{}, status.Inventory...)
+	if !status.InventoryInitialized {
+		ownedObjs, _, err := r.ownedResources(r.log, cr, nil)
+		if err != nil {
+			return err
+		}
+		for _, obj := range ownedObjs {
+			key, err := r.resourceKey(obj)
+			if err != nil {
+				return err
+			}
+			inventory = append(inventory, managedResource(key, r.writtenVersion(obj.(metav1.Object))))
+		}
+	}
+
+	index := make(map[resourceKey]int, len(inventory))
+	for i, resource := range inventory {
+		index[inventoryKey(resource)] = i
+	}
+	changed := !status.InventoryInitialized
+	for _, key := range keys {
+		if i, ok := index[key]; ok {
+			if inventory[i].OperatorVersion != operatorVersion {
+				inventory[i].OperatorVersion = operatorVersion
+				changed = true
+			}
+			continue
+		}
+		index[key] = len(inventory)
+		inventory = append(inventory, managedResource(key, operatorVersion))
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	status.Inventory = inventory
+	status.InventoryInitialized = true
+	return r.CrUpdate(status.Phase, cr)
+}
+
+// removeFromInventory removes the resources from the inventory of the CR
+func (r *Reconciler) removeFromInventory(cr runtime.Object, keys []resourceKey) error {
+	if !r.usesInventory(cr) || len(keys) == 0 {
+		return nil
+	}
+	removed := make(map[resourceKey]bool, len(keys))
+	for _, key := range keys {
+		removed[key] = true
+	}
+
+	status := r.status(cr)
+	var inventory []sdkapi.ManagedResource
+	for _, resource := range status.Inventory {
+		if !removed[inventoryKey(resource)] {
+			inventory = append(inventory, resource)
+		}
+	}
+	if len(inventory) == len(status.Inventory) {
+		return nil
+	}
+
+	status.Inventory = inventory
+	return r.CrUpdate(status.Phase, cr)
+}
+
+// inventoryResources reads the resources recorded in the inventory of the CR, except for the skipped ones, and returns
+// the resources owned by the CR and the keys of the resources that no longer exist
+func (r *Reconciler) inventoryResources(logger logr.Logger, cr controllerutil.Object, skip map[resourceKey]bool) ([]runtime.Object, []resourceKey, error) {
+	var ownedObjs []runtime.Object
+	var goneKeys []resourceKey
+	for _, resource := range r.status(cr).Inventory {
+		key := inventoryKey(resource)
+		if skip[key] {
+			continue
+		}
+
+		obj, err := r.scheme.New(key.gvk)
+		if err != nil {
+			// kinds unknown to the scheme are read as unstructured objects
+			u := &unstructured.Unstructured{}
+			u.SetGroupVersionKind(key.gvk)
+			obj = u
+		}
+		if err = r.getResource(client.ObjectKey{Namespace: key.namespace, Name: key.name}, obj); err != nil {
+			if errors.IsNotFound(err) {
+				goneKeys = append(goneKeys, key)
+				continue
+			}
+			logger.Error(err, "Error reading resource", "resource", key)
+			return nil, nil, err
+		}
+
+		if r.isOwnedBy(cr, obj.(metav1.Object)) && !r.isOptedOut(obj) {
+			ownedObjs = append(ownedObjs, obj)
+		}
+	}
+	return ownedObjs, goneKeys, nil
+}
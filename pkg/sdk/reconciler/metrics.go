@@ -0,0 +1,135 @@
+package reconciler
+
+import (
+	"time"
+
+	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/callbacks"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Operations on the managed resources counted by the resource operations metric
+const (
+	resourceCreated = "created"
+	resourceUpdated = "updated"
+	resourceDeleted = "deleted"
+	resourceFailed  = "failed"
+)
+
+var (
+	phases = []sdkapi.Phase{
+		sdkapi.PhaseDeploying,
+		sdkapi.PhaseDeployed,
+		sdkapi.PhaseDeleting,
+		sdkapi.PhaseDeleted,
+		sdkapi.PhaseError,
+		sdkapi.PhaseUpgrading,
+	}
+	conditionStatuses = []corev1.ConditionStatus{corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown}
+
+	crPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lifecycle_operator_cr_phase",
+		Help: "Phase of the config CR; 1 for the current phase, 0 for the others",
+	}, []string{"kind", "namespace", "name", "phase"})
+	crCondition = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lifecycle_operator_cr_condition",
+		Help: "Status of the config CR conditions; 1 for the current status, 0 for the others",
+	}, []string{"kind", "namespace", "name", "condition", "status"})
+	resourceOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lifecycle_operator_resource_operations_total",
+		Help: "Number of created, updated, deleted and failed to be written managed resources",
+	}, []string{"group", "version", "kind", "operation"})
+	upgradeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "lifecycle_operator_upgrade_duration_seconds",
+		Help:    "Duration of the completed upgrades",
+		Buckets: prometheus.ExponentialBuckets(10, 2, 10),
+	})
+	refusedDowngrades = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "lifecycle_operator_refused_downgrades_total",
+		Help: "Number of refused operator downgrades",
+	})
+	callbackDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "lifecycle_operator_callback_duration_seconds",
+		Help: "Duration of the callbacks invocation per reconcile state",
+	}, []string{"state"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(crPhase, crCondition, resourceOperations, upgradeDuration, refusedDowngrades, callbackDuration)
+}
+
+// recordStatusMetrics exposes the phase and the conditions of the CR
+func (r *Reconciler) recordStatusMetrics(cr runtime.Object) {
+	gvk, err := apiutil.GVKForObject(cr, r.scheme)
+	if err != nil {
+		r.log.Error(err, "Unable to record CR metrics")
+		return
+	}
+	metaObj := cr.(metav1.Object)
+	status := r.status(cr)
+
+	for _, phase := range phases {
+		value := 0.0
+		if phase == status.Phase {
+			value = 1
+		}
+		crPhase.WithLabelValues(gvk.Kind, metaObj.GetNamespace(), metaObj.GetName(), string(phase)).Set(value)
+	}
+
+	for _, condition := range status.Conditions {
+		for _, conditionStatus := range conditionStatuses {
+			value := 0.0
+			if conditionStatus == condition.Status {
+				value = 1
+			}
+			crCondition.WithLabelValues(gvk.Kind, metaObj.GetNamespace(), metaObj.GetName(), string(condition.Type), string(conditionStatus)).Set(value)
+		}
+	}
+}
+
+// deleteStatusMetrics removes the phase and the conditions of the deleted CR from the exposed metrics
+func (r *Reconciler) deleteStatusMetrics(cr runtime.Object) {
+	gvk, err := apiutil.GVKForObject(cr, r.scheme)
+	if err != nil {
+		r.log.Error(err, "Unable to delete CR metrics")
+		return
+	}
+	metaObj := cr.(metav1.Object)
+
+	for _, phase := range phases {
+		crPhase.DeleteLabelValues(gvk.Kind, metaObj.GetNamespace(), metaObj.GetName(), string(phase))
+	}
+	for _, condition := range r.status(cr).Conditions {
+		for _, conditionStatus := range conditionStatuses {
+			crCondition.DeleteLabelValues(gvk.Kind, metaObj.GetNamespace(), metaObj.GetName(), string(condition.Type), string(conditionStatus))
+		}
+	}
+}
+
+// countResourceOperation counts the operation on the managed resource
+func (r *Reconciler) countResourceOperation(obj runtime.Object, operation string) {
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		r.log.Error(err, "Unable to record resource metrics")
+		return
+	}
+	resourceOperations.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind, operation).Inc()
+}
+
+// observeUpgradeDuration observes the duration of the upgrade in progress
+func observeUpgradeDuration(status *sdkapi.Status) {
+	if status.UpgradeInProgress == nil {
+		return
+	}
+	upgradeDuration.Observe(time.Since(status.UpgradeInProgress.StartTime.Time).Seconds())
+}
+
+// observeCallbackDuration observes the duration of the callbacks invoked in given state since start
+func observeCallbackDuration(s callbacks.ReconcileState, start time.Time) {
+	callbackDuration.WithLabelValues(string(s)).Observe(time.Since(start).Seconds())
+}
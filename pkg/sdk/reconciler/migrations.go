@@ -0,0 +1,68 @@
+package reconciler
+
+import (
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Migration is expected to migrate data (i.e. rewrite ConfigMaps) on upgrade. It must be idempotent, because it is
+// executed again when the operator is restarted before the migration is recorded as completed
+type Migration func(logger logr.Logger, cr controllerutil.Object) error
+
+// migration is a Migration executed on upgrades entering its version range
+type migration struct {
+	name         string
+	versionRange semver.Range
+	migrate      Migration
+}
+
+// applies checks whether the migration has to be executed on upgrade from the current version to the target one; the
+// migration is executed when the target version is in the range and the current one is not
+func (m migration) applies(currentVersion, targetVersion string) bool {
+	target, err := semver.Make(strings.TrimPrefix(targetVersion, "v"))
+	if err != nil || !m.versionRange(target) {
+		return false
+	}
+	current, err := semver.Make(strings.TrimPrefix(currentVersion, "v"))
+	return err != nil || !m.versionRange(current)
+}
+
+// runMigrations executes, in the order of registration, the migrations applying to the upgrade from the current
+// version to the target one, which follow the last completed migration recorded in the CR status
+func (r *Reconciler) runMigrations(logger logr.Logger, cr controllerutil.Object, currentVersion, targetVersion string) error {
+	var pending []migration
+	for _, m := range r.migrations {
+		if m.applies(currentVersion, targetVersion) {
+			pending = append(pending, m)
+		}
+	}
+
+	status := r.status(cr)
+	for i, m := range pending {
+		if m.name == status.LastMigration {
+			pending = pending[i+1:]
+			break
+		}
+	}
+
+	for _, m := range pending {
+		logger.Info("Executing migration", "migration", m.name, "from version", currentVersion, "to version", targetVersion)
+		if err := m.migrate(logger, cr); err != nil {
+			return err
+		}
+
+		// the completed migration is recorded right away, so that it is not executed again after a restart
+		status.LastMigration = m.name
+		if err := r.CrUpdate(status.Phase, cr); err != nil {
+			return err
+		}
+		if err := r.flushCrStatus(cr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
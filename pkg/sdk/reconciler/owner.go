@@ -0,0 +1,144 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// canOwn checks whether the CR can be the controller owner of the resource. Namespaced CRs cannot own cluster-scoped
+// resources nor resources in other namespaces
+func canOwn(cr, obj metav1.Object) bool {
+	return cr.GetNamespace() == "" || cr.GetNamespace() == obj.GetNamespace()
+}
+
+// ownerReference identifies the CR in the owner annotation
+func ownerReference(cr metav1.Object) string {
+	if cr.GetNamespace() == "" {
+		return cr.GetName()
+	}
+	return cr.GetNamespace() + "/" + cr.GetName()
+}
+
+// annotatedOwnerRequests maps the resource marked with the owner annotation to the request of the owning CR
+func (r *Reconciler) annotatedOwnerRequests(obj handler.MapObject) []reconcile.Request {
+	owner, ok := obj.Meta.GetAnnotations()[r.ownerAnnotation]
+	if !ok || owner == "" {
+		return nil
+	}
+	name := types.NamespacedName{Name: owner}
+	if i := strings.Index(owner, "/"); i >= 0 {
+		name = types.NamespacedName{Namespace: owner[:i], Name: owner[i+1:]}
+	}
+	return []reconcile.Request{{NamespacedName: name}}
+}
+
+// setOwner makes the CR the controller owner of the resource; resources the CR cannot own are marked with the owner
+// annotation instead
+func (r *Reconciler) setOwner(cr controllerutil.Object, obj metav1.Object) error {
+	if canOwn(cr, obj) {
+		return controllerutil.SetControllerReference(cr, obj, r.scheme)
+	}
+	if r.ownerAnnotation == "" {
+		return fmt.Errorf("resource %s/%s cannot be owned by namespaced CR %s, owner annotation not configured",
+			obj.GetNamespace(), obj.GetName(), ownerReference(cr))
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[r.ownerAnnotation] = ownerReference(cr)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// isOwnedBy checks whether the resource is controlled by the CR or marked with its owner annotation
+func (r *Reconciler) isOwnedBy(cr, obj metav1.Object) bool {
+	if metav1.IsControlledBy(obj, cr) {
+		return true
+	}
+	return r.ownerAnnotation != "" && obj.GetAnnotations()[r.ownerAnnotation] == ownerReference(cr)
+}
+
+// ownedResources lists the managed resources owned by the CR, except for the skipped and the opted out ones, and the
+// keys of the resources recorded in the inventory that no longer exist. The resources are read from the inventory when
+// in use, or listed otherwise
+func (r *Reconciler) ownedResources(logger logr.Logger, cr controllerutil.Object, skip map[resourceKey]bool) ([]runtime.Object, []resourceKey, error) {
+	if r.usesInventory(cr) {
+		return r.inventoryResources(logger, cr, skip)
+	}
+
+	ls, err := labels.Parse(r.createVersionLabel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ownedObjs []runtime.Object
+	for _, lt := range r.crManager.GetDependantResourcesListObjects() {
+		lo := &client.ListOptions{LabelSelector: ls}
+
+		// lists can span namespaces, so only the policy of the kind applies
+		if err := r.readerFor(lt, false).List(context.TODO(), lt, lo); err != nil {
+			logger.Error(err, "Error listing resources")
+			return nil, nil, err
+		}
+
+		items, err := meta.ExtractList(lt)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, observedObj := range items {
+			key, err := r.resourceKey(observedObj)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !skip[key] && r.isOwnedBy(cr, observedObj.(metav1.Object)) && !r.isOptedOut(observedObj) {
+				ownedObjs = append(ownedObjs, observedObj)
+			}
+		}
+	}
+
+	return ownedObjs, nil, nil
+}
+
+// deleteAnnotatedResources deletes the resources marked with the owner annotation of the deleted CR, which are not
+// removed by the garbage collector
+func (r *Reconciler) deleteAnnotatedResources(logger logr.Logger, cr controllerutil.Object) error {
+	if r.ownerAnnotation == "" || cr.GetNamespace() == "" {
+		return nil
+	}
+
+	ownedObjs, _, err := r.ownedResources(logger, cr, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, observedObj := range ownedObjs {
+		observedMetaObj := observedObj.(metav1.Object)
+		if canOwn(cr, observedMetaObj) {
+			continue
+		}
+
+		logger.Info("Deleting resource owned by annotation", "namespace", observedMetaObj.GetNamespace(), "name", observedMetaObj.GetName())
+		if err = r.client.Delete(context.TODO(), observedObj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.recordResourceEvent(cr, ResourceDeletedReason, "Deleted", observedObj)
+		r.countResourceOperation(observedObj, resourceDeleted)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxCrPatchAttempts is the number of attempts to patch the CR failing on conflicts
+const maxCrPatchAttempts = 5
+
+// optimisticMergePatch is a JSON merge patch, which fails on conflict when the patched object has been modified since
+// the original object was read
+type optimisticMergePatch struct {
+	original runtime.Object
+}
+
+func (p *optimisticMergePatch) Type() types.PatchType {
+	return types.MergePatchType
+}
+
+func (p *optimisticMergePatch) Data(obj runtime.Object) ([]byte, error) {
+	data, err := client.MergeFrom(p.original).Data(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := map[string]interface{}{}
+	if err = json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	metadata, ok := patch["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+	}
+	metadata["resourceVersion"] = p.original.(metav1.Object).GetResourceVersion()
+	patch["metadata"] = metadata
+	return json.Marshal(patch)
+}
+
+// patchCrStatus writes the status of the CR to the status subresource; CRDs without the status subresource are
+// patched directly. The patch is computed against the original object, when given, or against a fresh copy of the CR
+func (r *Reconciler) patchCrStatus(cr, original runtime.Object) error {
+	status := r.status(cr)
+	return r.patchCr(cr, original, true, func(current runtime.Object) {
+		status.DeepCopyInto(r.status(current))
+	})
+}
+
+// addCrFinalizer adds the finalizer to the CR metadata
+func (r *Reconciler) addCrFinalizer(cr runtime.Object, finalizer string) error {
+	metaObj := cr.(metav1.Object)
+	if !hasFinalizer(metaObj, finalizer) {
+		metaObj.SetFinalizers(append(metaObj.GetFinalizers(), finalizer))
+	}
+	return r.patchCr(cr, nil, false, func(current runtime.Object) {
+		currentMetaObj := current.(metav1.Object)
+		if !hasFinalizer(currentMetaObj, finalizer) {
+			currentMetaObj.SetFinalizers(append(currentMetaObj.GetFinalizers(), finalizer))
+		}
+	})
+}
+
+// removeCrFinalizer removes the finalizer from the CR metadata
+func (r *Reconciler) removeCrFinalizer(cr runtime.Object, finalizer string) error {
+	metaObj := cr.(metav1.Object)
+	metaObj.SetFinalizers(withoutFinalizer(metaObj.GetFinalizers(), finalizer))
+	return r.patchCr(cr, nil, false, func(current runtime.Object) {
+		currentMetaObj := current.(metav1.Object)
+		currentMetaObj.SetFinalizers(withoutFinalizer(currentMetaObj.GetFinalizers(), finalizer))
+	})
+}
+
+// patchCr applies the mutation to the original object, or to a fresh copy of the CR, and patches the CR, or its status
+// subresource, with the result. Patches conflicting with concurrent changes of the CR are retried with a fresh copy
+func (r *Reconciler) patchCr(cr, original runtime.Object, statusOnly bool, mutate func(current runtime.Object)) error {
+	key, err := client.ObjectKeyFromObject(cr)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		current := original
+		original = nil
+		if current == nil {
+			current = r.crManager.Create()
+			if err = r.client.Get(context.TODO(), key, current); err != nil {
+				return err
+			}
+		}
+		patched := current.DeepCopyObject()
+		mutate(patched)
+
+		if !reflect.DeepEqual(current, patched) {
+			patch := &optimisticMergePatch{original: current}
+			if statusOnly {
+				err = r.client.Status().Patch(context.TODO(), patched, patch)
+				if errors.IsNotFound(err) {
+					err = r.client.Patch(context.TODO(), patched, patch)
+				}
+			} else {
+				err = r.client.Patch(context.TODO(), patched, patch)
+			}
+		}
+
+		if err == nil {
+			cr.(metav1.Object).SetResourceVersion(patched.(metav1.Object).GetResourceVersion())
+			r.updateStatusBatchOriginal(cr, patched)
+			return nil
+		}
+		if !errors.IsConflict(err) || attempt == maxCrPatchAttempts {
+			return err
+		}
+		r.log.V(3).Info("Conflict while patching CR, retrying", "name", key.Name, "attempt", attempt)
+	}
+}
+
+func hasFinalizer(metaObj metav1.Object, finalizer string) bool {
+	for _, f := range metaObj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutFinalizer(finalizers []string, finalizer string) []string {
+	var result []string
+	for _, f := range finalizers {
+		if f != finalizer {
+			result = append(result, f)
+		}
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package reconciler
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
+	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// ConditionPaused is the type of the CR condition reporting paused reconciliation
+	ConditionPaused conditions.ConditionType = "Paused"
+	// PausedReason is the reason of the Paused condition
+	PausedReason = "PausedByAnnotation"
+)
+
+// annotationEnabled checks whether the annotation is set to "true" on the object
+func annotationEnabled(obj runtime.Object, annotation string) bool {
+	if annotation == "" {
+		return false
+	}
+	return obj.(metav1.Object).GetAnnotations()[annotation] == "true"
+}
+
+// isPaused checks whether the reconciliation of the CR is paused by the pause annotation
+func (r *Reconciler) isPaused(cr runtime.Object) bool {
+	return annotationEnabled(cr, r.pauseAnnotation)
+}
+
+// isOptedOut checks whether the managed resource is excluded from the reconciliation by the opt-out annotation
+func (r *Reconciler) isOptedOut(obj runtime.Object) bool {
+	return annotationEnabled(obj, r.optOutAnnotation)
+}
+
+// reconcilePaused refreshes the status conditions of the paused CR without writing the managed resources. CRs not
+// created yet only get the Paused condition, which is removed before the creation once the CR is resumed
+func (r *Reconciler) reconcilePaused(logger logr.Logger, cr runtime.Object) (reconcile.Result, error) {
+	logger.Info("Reconciliation paused", "annotation", r.pauseAnnotation)
+	status := r.status(cr)
+	currentConditions := append([]conditions.Condition(nil), status.Conditions...)
+
+	conditions.SetStatusCondition(&status.Conditions, conditions.Condition{
+		Type:    ConditionPaused,
+		Status:  corev1.ConditionTrue,
+		Reason:  PausedReason,
+		Message: fmt.Sprintf("Reconciliation paused by annotation %s", r.pauseAnnotation),
+	})
+	var err error
+	if status.Phase != sdkapi.PhaseEmpty {
+		_, err = r.CheckDegraded(logger, cr)
+	}
+
+	if sdk.ConditionsChanged(sdk.GetConditionValues(currentConditions), sdk.GetConditionValues(status.Conditions)) ||
+		sdk.ConditionMessagesChanged(currentConditions, status.Conditions) {
+		if updateErr := r.CrUpdate(status.Phase, cr); updateErr != nil {
+			return reconcile.Result{}, updateErr
+		}
+	}
+	return reconcile.Result{}, err
+}
+
+// resume removes the Paused condition of the CR and reports whether it was set
+func (r *Reconciler) resume(cr runtime.Object) bool {
+	status := r.status(cr)
+	if conditions.FindStatusCondition(status.Conditions, ConditionPaused) == nil {
+		return false
+	}
+	conditions.RemoveStatusCondition(&status.Conditions, ConditionPaused)
+	return true
+}
@@ -0,0 +1,152 @@
+package reconciler
+
+import (
+	"reflect"
+
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ReconcilePlan lists the changes of the managed resources the reconciliation of the CR would make
+type ReconcilePlan struct {
+	Creates []PlannedChange
+	Updates []PlannedChange
+	Deletes []PlannedChange
+}
+
+// PlannedChange describes the change of a single managed resource
+type PlannedChange struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	// Object is the object to be created, the updated object or the object to be deleted
+	Object runtime.Object
+	// Patch is the JSON patch from the current object to the updated one; set only for updates
+	Patch []byte
+}
+
+// IsEmpty checks whether the plan contains no changes
+func (p *ReconcilePlan) IsEmpty() bool {
+	return len(p.Creates) == 0 && len(p.Updates) == 0 && len(p.Deletes) == 0
+}
+
+// Plan computes the changes of the managed resources ReconcileUpdate and CleanupUnusedResources would make, without
+// writing to the cluster. Callbacks are not invoked and the rollout waves are not awaited. With server-side apply the
+// updates are computed by dry-run apply requests
+func (r *Reconciler) Plan(cr controllerutil.Object) (*ReconcilePlan, error) {
+	resources, err := r.crManager.GetAllResources(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ReconcilePlan{}
+	for _, desiredRuntimeObj := range resources {
+		desiredRuntimeObj = desiredRuntimeObj.DeepCopyObject()
+		change, exists, err := r.planResource(cr, desiredRuntimeObj)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !exists:
+			plan.Creates = append(plan.Creates, *change)
+		case change.Patch != nil:
+			plan.Updates = append(plan.Updates, *change)
+		}
+	}
+
+	if plan.Deletes, err = r.PlanPruning(cr); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// PlanPruning lists the resources owned by the CR, which are no longer desired and would be removed by
+// CleanupUnusedResources, without removing them
+func (r *Reconciler) PlanPruning(cr controllerutil.Object) ([]PlannedChange, error) {
+	unusedObjs, _, err := r.unusedResources(r.log, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	var deletes []PlannedChange
+	for _, obj := range unusedObjs {
+		change, err := r.plannedChange(obj)
+		if err != nil {
+			return nil, err
+		}
+		deletes = append(deletes, *change)
+	}
+	return deletes, nil
+}
+
+// planResource computes the change of single desired resource; the patch of the returned change is nil when an existing
+// resource is unchanged
+func (r *Reconciler) planResource(cr controllerutil.Object, desiredRuntimeObj runtime.Object) (*PlannedChange, bool, error) {
+	currentRuntimeObj := sdk.NewDefaultInstance(desiredRuntimeObj)
+	key, err := client.ObjectKeyFromObject(desiredRuntimeObj)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = r.getResource(key, currentRuntimeObj); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, false, err
+		}
+		if err = r.setOwner(cr, desiredRuntimeObj.(metav1.Object)); err != nil {
+			return nil, false, err
+		}
+		change, err := r.plannedChange(desiredRuntimeObj)
+		return change, false, err
+	}
+
+	if r.isOptedOut(currentRuntimeObj) {
+		return &PlannedChange{}, true, nil
+	}
+
+	var beforeObj, afterObj runtime.Object
+	if r.fieldManager != "" {
+		gvk, err := apiutil.GVKForObject(desiredRuntimeObj, r.scheme)
+		if err != nil {
+			return nil, true, err
+		}
+		beforeObj, afterObj, err = r.dryRunApply(cr, desiredRuntimeObj, currentRuntimeObj, gvk)
+		if err != nil {
+			return nil, true, err
+		}
+	} else {
+		beforeObj, err = sdk.StripStatusFromObject(currentRuntimeObj)
+		if err != nil {
+			return nil, true, err
+		}
+		afterObj, err = r.mergeDesired(desiredRuntimeObj, beforeObj.DeepCopyObject())
+		if err != nil {
+			return nil, true, err
+		}
+	}
+
+	change, err := r.plannedChange(afterObj)
+	if err != nil || reflect.DeepEqual(beforeObj, afterObj) {
+		return change, true, err
+	}
+	change.Patch, err = sdk.CreateJSONPatch(beforeObj, afterObj)
+	return change, true, err
+}
+
+func (r *Reconciler) plannedChange(obj runtime.Object) (*PlannedChange, error) {
+	key, err := r.resourceKey(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &PlannedChange{
+		GroupVersionKind: key.gvk,
+		Namespace:        key.namespace,
+		Name:             key.name,
+		Object:           obj,
+	}, nil
+}
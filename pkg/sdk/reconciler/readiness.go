@@ -0,0 +1,108 @@
+package reconciler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// resourcesNotReadyReason is the reason of the CR conditions caused by unready resources
+	resourcesNotReadyReason = "ResourcesNotReady"
+	// maxReportedUnreadyResources limits the number of unready resources named in the CR conditions
+	maxReportedUnreadyResources = 5
+)
+
+// UnreadyResource describes a managed resource that is not ready
+type UnreadyResource struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	// Message explains why the resource is not ready
+	Message string
+}
+
+func (u UnreadyResource) String() string {
+	name := u.Name
+	if u.Namespace != "" {
+		name = u.Namespace + "/" + u.Name
+	}
+	return fmt.Sprintf("%s %s: %s", u.GroupVersionKind.Kind, name, u.Message)
+}
+
+// CheckReadiness checks readiness of the resources managed by the CR, which kinds have a readiness checker registered,
+// and returns the ones that are not ready
+func (r *Reconciler) CheckReadiness(cr runtime.Object) ([]UnreadyResource, error) {
+	resources, err := r.crManager.GetAllResources(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.checkResourcesReadiness(resources, false)
+}
+
+// checkResourcesReadiness returns the resources that are not ready. Resources of the kinds without a readiness checker
+// are ready, or, when checkExistence is set, ready once they exist
+func (r *Reconciler) checkResourcesReadiness(resources []runtime.Object, checkExistence bool) ([]UnreadyResource, error) {
+	var unready []UnreadyResource
+	for _, desiredObj := range resources {
+		key, err := r.resourceKey(desiredObj)
+		if err != nil {
+			return nil, err
+		}
+
+		checker, ok := r.readinessCheckers.CheckerFor(key.gvk)
+		if !ok && !checkExistence {
+			continue
+		}
+
+		currentObj := sdk.NewDefaultInstance(desiredObj)
+		if err = r.getResource(client.ObjectKey{Namespace: key.namespace, Name: key.name}, currentObj); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, err
+			}
+			unready = append(unready, UnreadyResource{GroupVersionKind: key.gvk, Namespace: key.namespace, Name: key.name, Message: "not found"})
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+		ready, message, err := checker(r.readinessClient(), currentObj)
+		if err != nil {
+			return nil, err
+		}
+		if !ready {
+			unready = append(unready, UnreadyResource{GroupVersionKind: key.gvk, Namespace: key.namespace, Name: key.name, Message: message})
+		}
+	}
+
+	return unready, nil
+}
+
+// readinessClient returns the client passed to the readiness checkers. The uncached client is preferred, so that the
+// Pods and Endpoints inspected by the checkers are read without starting cluster-wide informers for them
+func (r *Reconciler) readinessClient() client.Client {
+	if r.uncachedClient != nil {
+		return r.uncachedClient
+	}
+	return r.client
+}
+
+// unreadyMessage describes the first few unready resources
+func unreadyMessage(unready []UnreadyResource) string {
+	var messages []string
+	for i, resource := range unready {
+		if i == maxReportedUnreadyResources {
+			messages = append(messages, fmt.Sprintf("and %d more", len(unready)-maxReportedUnreadyResources))
+			break
+		}
+		messages = append(messages, resource.String())
+	}
+	return fmt.Sprintf("%d resources not ready: %s", len(unready), strings.Join(messages, "; "))
+}
@@ -0,0 +1,44 @@
+package reconciler
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// readerFor returns the client reading the managed resources of the kind of given object in given scope: the uncached
+// client for the kinds registered for uncached reads and, when enabled, for the cluster-scoped resources; the cached
+// client otherwise
+func (r *Reconciler) readerFor(obj runtime.Object, clusterScoped bool) client.Reader {
+	if r.uncachedClient == nil {
+		return r.client
+	}
+	if r.uncachedClusterScoped && clusterScoped {
+		return r.uncachedClient
+	}
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return r.client
+	}
+	// list types follow the policy of their items
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	if r.uncachedKinds[gvk] {
+		return r.uncachedClient
+	}
+	return r.client
+}
+
+// getResource reads the managed resource with the client selected by the read policy. With the fallback enabled,
+// resources missing in the cache are read with the uncached client, because the cache may not cover them
+func (r *Reconciler) getResource(key client.ObjectKey, obj runtime.Object) error {
+	reader := r.readerFor(obj, key.Namespace == "")
+	err := reader.Get(context.TODO(), key, obj)
+	if errors.IsNotFound(err) && r.uncachedFallback && r.uncachedClient != nil && reader != client.Reader(r.uncachedClient) {
+		return r.uncachedClient.Get(context.TODO(), key, obj)
+	}
+	return err
+}
@@ -20,9 +20,12 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -80,6 +83,14 @@ type Reconciler struct {
 	log        logr.Logger
 
 	client client.Client
+	// uncachedClient reads the managed resources selected by the read policy when set
+	uncachedClient        client.Client
+	uncachedKinds         map[schema.GroupVersionKind]bool
+	uncachedClusterScoped bool
+	uncachedFallback      bool
+
+	// buildErr is the first error of the builder options
+	buildErr error
 
 	callbackDispatcher          CallbackDispatcher
 	createVersionLabel          string
@@ -88,6 +99,43 @@ type Reconciler struct {
 	scheme                      *runtime.Scheme
 	perishablesSyncInterval     time.Duration
 	finalizerName               string
+	// fieldManager enables server-side apply of the managed resources when not empty
+	fieldManager    string
+	mergeStrategies *sdk.MergeStrategyRegistry
+	// rolloutWaveAnnotation enables ordered rollout of the managed resources when not empty
+	rolloutWaveAnnotation string
+	readinessCheckers     *sdk.ReadinessCheckerRegistry
+	// recorder records events on the CR when set
+	recorder                  record.EventRecorder
+	defaultDowngradePolicy    DowngradePolicy
+	downgradePolicyAnnotation string
+	// upgradeGraph constrains the upgrades when set
+	upgradeGraph *UpgradeGraph
+	migrations   []migration
+	// upgradeHistoryLimit is the number of the finished upgrades kept in the CR status
+	upgradeHistoryLimit int
+	// upgradeTimeout fails the upgrades not completed in time when not zero
+	upgradeTimeout time.Duration
+	// workers is the number of the resources reconciled concurrently
+	workers int
+	// statusBatches holds the status changes of the CRs being reconciled
+	statusBatches sync.Map
+	// rollbackAnnotation enables rollback of the failed upgrades when not empty
+	rollbackAnnotation string
+	// pauseAnnotation enables pausing the reconciliation of the CR when not empty
+	pauseAnnotation string
+	// optOutAnnotation enables excluding managed resources from the reconciliation when not empty
+	optOutAnnotation string
+	// ownerAnnotation marks the resources a namespaced CR cannot own when not empty
+	ownerAnnotation string
+	// singleton enables reconciling only the CR named singletonName, or the oldest CR when the name is empty
+	singleton     bool
+	singletonName string
+	orphanPolicy  OrphanPolicy
+	// pruning enables removing the unused resources on every reconciliation
+	pruning bool
+	// inventory enables recording the applied resources in the CR status
+	inventory bool
 
 	// Hooks
 	syncPerishables               PerishablesSynchronizer
@@ -99,6 +147,10 @@ type Reconciler struct {
 
 // Reconcile performs request reconciliation
 func (r *Reconciler) Reconcile(request reconcile.Request, operatorVersion string, reqLogger logr.Logger) (reconcile.Result, error) {
+	if r.buildErr != nil {
+		return reconcile.Result{}, r.buildErr
+	}
+
 	// Fetch the CR instance
 	// check at cluster level
 	cr, err := r.getCr(request.NamespacedName)
@@ -112,6 +164,20 @@ func (r *Reconciler) Reconcile(request reconcile.Request, operatorVersion string
 		return reconcile.Result{}, err
 	}
 
+	// status changes are written once, also when the reconciliation fails
+	r.beginStatusBatch(cr)
+	res, err := r.reconcileCr(cr, operatorVersion, reqLogger)
+	if flushErr := r.endStatusBatch(cr); flushErr != nil {
+		reqLogger.Error(flushErr, "Error writing CR status")
+		if err != nil {
+			return res, utilerrors.NewAggregate([]error{err, flushErr})
+		}
+		return reconcile.Result{}, flushErr
+	}
+	return res, err
+}
+
+func (r *Reconciler) reconcileCr(cr controllerutil.Object, operatorVersion string, reqLogger logr.Logger) (reconcile.Result, error) {
 	// make sure we're watching eveything
 	if err := r.WatchDependantResources(cr); err != nil {
 		return reconcile.Result{}, err
@@ -124,10 +190,33 @@ func (r *Reconciler) Reconcile(request reconcile.Request, operatorVersion string
 	}
 
 	status := r.status(cr)
+	if r.singleton {
+		message, err := r.singletonRejection(cr)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if message != "" {
+			return r.rejectCr(reqLogger, cr, message)
+		}
+	}
+	if failedWithReason(status, SingletonRejectedReason, OrphanResourcesReason, ForeignOwnedResourcesReason) {
+		// the CR was blocked before the managed resources were created, so it is created from scratch
+		status.Phase = ""
+		status.Conditions = nil
+	}
+
+	// paused CRs are neither created nor updated
+	if r.isPaused(cr) {
+		return r.reconcilePaused(reqLogger, cr)
+	}
+	resumed := r.resume(cr)
+
 	creating, err := r.crManager.IsCreating(cr)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	// the CR waiting for the orphans keeps its conditions, but its managed resources have not been created yet
+	creating = creating || waitingForOrphans(status)
 
 	if creating {
 		if status.Phase != "" {
@@ -136,13 +225,15 @@ func (r *Reconciler) Reconcile(request reconcile.Request, operatorVersion string
 			return r.ReconcileError(cr, "Reconciling to error state, illegal phase")
 		}
 
-		haveOrphans, err := r.CheckForOrphans(reqLogger, cr)
+		orphans, err := r.findOrphans(reqLogger, cr)
 		if err != nil {
 			return reconcile.Result{}, err
 		}
 
-		if haveOrphans {
-			return reconcile.Result{RequeueAfter: time.Second}, nil
+		if len(orphans) > 0 {
+			if result, err := r.reconcileOrphans(reqLogger, cr, orphans, operatorVersion); result != nil {
+				return *result, err
+			}
 		}
 		reqLogger.Info("Doing reconcile create")
 		if err := r.preCreate(cr); err != nil {
@@ -167,10 +258,16 @@ func (r *Reconciler) Reconcile(request reconcile.Request, operatorVersion string
 	}
 
 	currentConditionValues := sdk.GetConditionValues(status.Conditions)
+	currentConditions := append([]conditions.Condition(nil), status.Conditions...)
+
 	reqLogger.Info("Doing reconcile update")
 
 	res, err := r.ReconcileUpdate(reqLogger, cr, operatorVersion)
-	if sdk.ConditionsChanged(currentConditionValues, sdk.GetConditionValues(status.Conditions)) {
+	if err != nil && status.Phase == sdkapi.PhaseUpgrading {
+		r.recordEvent(cr, corev1.EventTypeWarning, UpgradeFailedReason, "Upgrade to version %s failed: %v", status.TargetVersion, err)
+	}
+	if resumed || sdk.ConditionsChanged(currentConditionValues, sdk.GetConditionValues(status.Conditions)) ||
+		sdk.ConditionMessagesChanged(currentConditions, status.Conditions) {
 		if err := r.CrUpdate(status.Phase, cr); err != nil {
 			return reconcile.Result{}, err
 		}
@@ -181,10 +278,19 @@ func (r *Reconciler) Reconcile(request reconcile.Request, operatorVersion string
 
 // ReconcileUpdate executes Update operation
 func (r *Reconciler) ReconcileUpdate(logger logr.Logger, cr controllerutil.Object, operatorVersion string) (reconcile.Result, error) {
+	if upgradeFailed(r.status(cr), operatorVersion) {
+		logger.Info("Upgrade to the operator version failed, will not reconcile", "version", operatorVersion)
+		return reconcile.Result{}, nil
+	}
+
 	if err := r.CheckUpgrade(logger, cr, operatorVersion); err != nil {
 		return reconcile.Result{}, err
 	}
 
+	if r.upgradeTimedOut(r.status(cr)) {
+		return reconcile.Result{}, r.failUpgrade(logger, cr)
+	}
+
 	if err := r.updateControllerConfiguration(cr); err != nil {
 		logger.Error(err, "Error while customizing controller configuration")
 		return reconcile.Result{}, err
@@ -195,116 +301,74 @@ func (r *Reconciler) ReconcileUpdate(logger logr.Logger, cr controllerutil.Objec
 		return reconcile.Result{}, err
 	}
 
-	var allErrors []error
-	for _, desiredRuntimeObj := range resources {
-		desiredMetaObj := desiredRuntimeObj.(metav1.Object)
-		currentRuntimeObj := sdk.NewDefaultInstance(desiredRuntimeObj)
+	waves, err := r.rolloutWaves(resources)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
 
-		key := client.ObjectKey{
-			Namespace: desiredMetaObj.GetNamespace(),
-			Name:      desiredMetaObj.GetName(),
+	var allErrors ReconcileErrors
+	var appliedKeys []resourceKey
+	upgraded, optedOut := 0, 0
+	rolledOut := true
+	for i, wave := range waves {
+		if err = r.setRolloutWave(cr, wave.number); err != nil {
+			return reconcile.Result{}, err
 		}
-		err = r.client.Get(context.TODO(), key, currentRuntimeObj)
 
-		if err != nil {
-			if !errors.IsNotFound(err) {
-				return reconcile.Result{}, err
-			}
-
-			r.setLastAppliedConfiguration(desiredMetaObj)
-			sdk.SetLabel(r.createVersionLabel, operatorVersion, desiredMetaObj)
-
-			if err = controllerutil.SetControllerReference(cr, desiredMetaObj, r.scheme); err != nil {
+		results := r.reconcileResources(logger, cr, wave.resources, operatorVersion)
+		for j, desiredRuntimeObj := range wave.resources {
+			writeErr, err := results[j].writeErr, results[j].err
+			if err != nil {
 				return reconcile.Result{}, err
 			}
-
-			// PRE_CREATE callback
-			if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePreCreate, desiredRuntimeObj, nil); err != nil {
+			key, err := r.resourceKey(desiredRuntimeObj)
+			if err != nil {
 				return reconcile.Result{}, err
 			}
-
-			currentRuntimeObj = desiredRuntimeObj.DeepCopyObject()
-			if err = r.client.Create(context.TODO(), currentRuntimeObj); err != nil {
-				logger.Error(err, "")
-				allErrors = append(allErrors, err)
-				continue
-			}
-
-			// POST_CREATE callback
-			if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostCreate, desiredRuntimeObj, nil); err != nil {
-				return reconcile.Result{}, err
+			if writeErr != nil {
+				r.countResourceOperation(desiredRuntimeObj, resourceFailed)
+				allErrors = append(allErrors, &ResourceError{GroupVersionKind: key.gvk, Namespace: key.namespace, Name: key.name, Err: writeErr})
+			} else if !results[j].optedOut {
+				appliedKeys = append(appliedKeys, key)
 			}
-
-			logger.Info("Resource created",
-				"namespace", desiredMetaObj.GetNamespace(),
-				"name", desiredMetaObj.GetName(),
-				"type", fmt.Sprintf("%T", desiredMetaObj))
-		} else {
-			// POST_READ callback
-			if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostRead, desiredRuntimeObj, currentRuntimeObj); err != nil {
-				return reconcile.Result{}, err
+			if results[j].optedOut {
+				optedOut++
+			} else if results[j].version == operatorVersion {
+				upgraded++
 			}
+		}
 
-			currentRuntimeObj, err = sdk.StripStatusFromObject(currentRuntimeObj)
+		// later waves depend on the resources of the current one
+		if len(allErrors) > 0 {
+			break
+		}
+		if i < len(waves)-1 {
+			ready, err := r.waveReady(logger, wave)
 			if err != nil {
 				return reconcile.Result{}, err
 			}
-			currentRuntimeObjCopy := currentRuntimeObj.DeepCopyObject()
-			currentMetaObj := currentRuntimeObj.(metav1.Object)
-
-			// allow users to add new annotations (but not change ours)
-			sdk.MergeLabelsAndAnnotations(desiredMetaObj, currentMetaObj)
-
-			if !sdk.IsMutable(currentRuntimeObj) {
-				r.setLastAppliedConfiguration(desiredMetaObj)
-
-				// overwrite currentRuntimeObj
-				currentRuntimeObj, err = sdk.MergeObject(desiredRuntimeObj, currentRuntimeObj, r.lastAppliedConfigAnnotation)
-				if err != nil {
-					return reconcile.Result{}, err
-				}
-				currentMetaObj = currentRuntimeObj.(metav1.Object)
-			}
-
-			if !reflect.DeepEqual(currentRuntimeObjCopy, currentRuntimeObj) {
-				sdk.LogJSONDiff(logger, currentRuntimeObjCopy, currentRuntimeObj)
-				sdk.SetLabel(r.updateVersionLabel, operatorVersion, currentMetaObj)
-
-				// PRE_UPDATE callback
-				if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePreUpdate, desiredRuntimeObj, currentRuntimeObj); err != nil {
-					return reconcile.Result{}, err
-				}
-
-				if err = r.client.Update(context.TODO(), currentRuntimeObj); err != nil {
-					logger.Error(err, "")
-					allErrors = append(allErrors, err)
-					continue
-				}
-
-				// POST_UPDATE callback
-				if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostUpdate, desiredRuntimeObj, nil); err != nil {
-					return reconcile.Result{}, err
-				}
-
-				logger.Info("Resource updated",
-					"namespace", desiredMetaObj.GetNamespace(),
-					"name", desiredMetaObj.GetName(),
-					"type", fmt.Sprintf("%T", desiredMetaObj))
-			} else {
-				logger.V(3).Info("Resource unchanged",
-					"namespace", desiredMetaObj.GetNamespace(),
-					"name", desiredMetaObj.GetName(),
-					"type", fmt.Sprintf("%T", desiredMetaObj))
+			if !ready {
+				logger.Info("Waiting for rollout wave to become ready", "wave", wave.number)
+				rolledOut = false
+				break
 			}
 		}
 	}
 
+	if err = r.updateInventory(cr, appliedKeys, operatorVersion); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err = r.setUpgradeProgress(cr, upgraded, len(resources)-optedOut); err != nil {
+		return reconcile.Result{}, err
+	}
+
 	if err = r.syncPerishables(); err != nil {
 		return reconcile.Result{}, err
 	}
 
 	if len(allErrors) > 0 {
-		return reconcile.Result{}, fmt.Errorf("reconcile encountered %d errors", len(allErrors))
+		return reconcile.Result{}, allErrors
 	}
 
 	degraded, err := r.CheckDegraded(logger, cr)
@@ -313,6 +377,17 @@ func (r *Reconciler) ReconcileUpdate(logger logr.Logger, cr controllerutil.Objec
 	}
 
 	status := r.status(cr)
+	if !rolledOut {
+		return reconcile.Result{RequeueAfter: r.upgradeRequeueAfter(status, rolloutWaveRequeueInterval)}, nil
+	}
+
+	// upgrades remove the unused resources once completed
+	if r.pruning && !sdk.IsUpgrading(status) {
+		if err = r.CleanupUnusedResources(logger, cr); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
 	if status.Phase != sdkapi.PhaseDeployed && !sdk.IsUpgrading(status) && !degraded {
 		//We are not moving to Deployed phase until new operator deployment is ready in case of Upgrade
 		status.ObservedVersion = operatorVersion
@@ -332,42 +407,202 @@ func (r *Reconciler) ReconcileUpdate(logger logr.Logger, cr controllerutil.Objec
 		}
 	}
 
-	return reconcile.Result{RequeueAfter: r.perishablesSyncInterval}, nil
+	return reconcile.Result{RequeueAfter: r.upgradeRequeueAfter(status, r.perishablesSyncInterval)}, nil
+}
+
+// reconcileResourceWithRetry reconciles single desired resource, retrying writes conflicting with concurrent changes
+// of the resource with its fresh copy
+func (r *Reconciler) reconcileResourceWithRetry(logger logr.Logger, cr controllerutil.Object, desiredRuntimeObj runtime.Object, operatorVersion string) resourceResult {
+	for attempt := 1; ; attempt++ {
+		// the desired object is modified while being reconciled
+		result := r.reconcileResource(logger, cr, desiredRuntimeObj.DeepCopyObject(), operatorVersion)
+		if result.err != nil || result.writeErr == nil || !errors.IsConflict(result.writeErr) || attempt == maxResourceWriteAttempts {
+			return result
+		}
+		logger.Info("Conflict while writing resource, retrying", "attempt", attempt)
+	}
+}
+
+// reconcileResource creates or updates single desired resource and reports the outcome in resourceResult
+func (r *Reconciler) reconcileResource(logger logr.Logger, cr controllerutil.Object, desiredRuntimeObj runtime.Object, operatorVersion string) resourceResult {
+	if r.fieldManager != "" {
+		return r.serverSideApply(logger, cr, desiredRuntimeObj, operatorVersion)
+	}
+
+	desiredMetaObj := desiredRuntimeObj.(metav1.Object)
+	currentRuntimeObj := sdk.NewDefaultInstance(desiredRuntimeObj)
+
+	key := client.ObjectKey{
+		Namespace: desiredMetaObj.GetNamespace(),
+		Name:      desiredMetaObj.GetName(),
+	}
+	err := r.getResource(key, currentRuntimeObj)
+
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return resourceResult{err: err}
+		}
+
+		r.setLastAppliedConfiguration(desiredMetaObj)
+		sdk.SetLabel(r.createVersionLabel, operatorVersion, desiredMetaObj)
+
+		if err = r.setOwner(cr, desiredMetaObj); err != nil {
+			return resourceResult{err: err}
+		}
+
+		// PRE_CREATE callback
+		if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePreCreate, desiredRuntimeObj, nil); err != nil {
+			return resourceResult{err: err}
+		}
+
+		currentRuntimeObj = desiredRuntimeObj.DeepCopyObject()
+		if err = r.client.Create(context.TODO(), currentRuntimeObj); err != nil {
+			logger.Error(err, "")
+			return resourceResult{writeErr: err}
+		}
+
+		// POST_CREATE callback
+		if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostCreate, desiredRuntimeObj, nil); err != nil {
+			return resourceResult{err: err}
+		}
+
+		logger.Info("Resource created",
+			"namespace", desiredMetaObj.GetNamespace(),
+			"name", desiredMetaObj.GetName(),
+			"type", fmt.Sprintf("%T", desiredMetaObj))
+		r.recordResourceEvent(cr, ResourceCreatedReason, "Created", desiredRuntimeObj)
+		r.countResourceOperation(desiredRuntimeObj, resourceCreated)
+	} else {
+		if r.isOptedOut(currentRuntimeObj) {
+			logger.V(3).Info("Resource opted out of reconciliation",
+				"namespace", desiredMetaObj.GetNamespace(),
+				"name", desiredMetaObj.GetName(),
+				"type", fmt.Sprintf("%T", desiredMetaObj))
+			return resourceResult{optedOut: true}
+		}
+
+		// POST_READ callback
+		if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostRead, desiredRuntimeObj, currentRuntimeObj); err != nil {
+			return resourceResult{err: err}
+		}
+
+		currentRuntimeObj, err = sdk.StripStatusFromObject(currentRuntimeObj)
+		if err != nil {
+			return resourceResult{err: err}
+		}
+		currentRuntimeObjCopy := currentRuntimeObj.DeepCopyObject()
+
+		// overwrite currentRuntimeObj
+		currentRuntimeObj, err = r.mergeDesired(desiredRuntimeObj, currentRuntimeObj)
+		if err != nil {
+			return resourceResult{err: err}
+		}
+		currentMetaObj := currentRuntimeObj.(metav1.Object)
+
+		if !reflect.DeepEqual(currentRuntimeObjCopy, currentRuntimeObj) {
+			sdk.LogJSONDiff(logger, currentRuntimeObjCopy, currentRuntimeObj)
+			r.setRollbackConfiguration(r.status(cr), currentRuntimeObjCopy.(metav1.Object), currentMetaObj, operatorVersion)
+			sdk.SetLabel(r.updateVersionLabel, operatorVersion, currentMetaObj)
+
+			// PRE_UPDATE callback
+			if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePreUpdate, desiredRuntimeObj, currentRuntimeObj); err != nil {
+				return resourceResult{err: err}
+			}
+
+			if err = r.client.Update(context.TODO(), currentRuntimeObj); err != nil {
+				logger.Error(err, "")
+				return resourceResult{writeErr: err, version: r.writtenVersion(currentRuntimeObjCopy.(metav1.Object))}
+			}
+
+			// POST_UPDATE callback
+			if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostUpdate, desiredRuntimeObj, nil); err != nil {
+				return resourceResult{err: err}
+			}
+
+			logger.Info("Resource updated",
+				"namespace", desiredMetaObj.GetNamespace(),
+				"name", desiredMetaObj.GetName(),
+				"type", fmt.Sprintf("%T", desiredMetaObj))
+			r.recordResourceEvent(cr, ResourceUpdatedReason, "Updated", desiredRuntimeObj)
+			r.countResourceOperation(desiredRuntimeObj, resourceUpdated)
+		} else {
+			logger.V(3).Info("Resource unchanged",
+				"namespace", desiredMetaObj.GetNamespace(),
+				"name", desiredMetaObj.GetName(),
+				"type", fmt.Sprintf("%T", desiredMetaObj))
+		}
+	}
+
+	return resourceResult{version: operatorVersion}
+}
+
+// mergeDesired merges the desired object into the current one, which status has been stripped, with the merge
+// strategy registered for its kind
+func (r *Reconciler) mergeDesired(desiredRuntimeObj, currentRuntimeObj runtime.Object) (runtime.Object, error) {
+	// allow users to add new annotations (but not change ours)
+	sdk.MergeLabelsAndAnnotations(desiredRuntimeObj.(metav1.Object), currentRuntimeObj.(metav1.Object))
+
+	r.setLastAppliedConfiguration(desiredRuntimeObj.(metav1.Object))
+
+	gvk, err := apiutil.GVKForObject(desiredRuntimeObj, r.scheme)
+	if err != nil {
+		return nil, err
+	}
+	return r.mergeStrategies.StrategyFor(gvk)(desiredRuntimeObj, currentRuntimeObj, r.lastAppliedConfigAnnotation)
 }
 
 // CheckForOrphans checks whether there are any orphaned resources (ones that exist in the cluster but shouldn't)
 func (r *Reconciler) CheckForOrphans(logger logr.Logger, cr runtime.Object) (bool, error) {
+	orphans, err := r.findOrphans(logger, cr)
+	return len(orphans) > 0, err
+}
+
+// findOrphans lists the desired resources, which exist in the cluster
+func (r *Reconciler) findOrphans(logger logr.Logger, cr runtime.Object) ([]runtime.Object, error) {
 	resources, err := r.crManager.GetAllResources(cr)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
+	var orphans []runtime.Object
 	for _, resource := range resources {
 		cpy := resource.DeepCopyObject()
 		key, err := client.ObjectKeyFromObject(cpy)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 
-		if err = r.client.Get(context.TODO(), key, cpy); err != nil {
+		if err = r.getResource(key, cpy); err != nil {
 			if errors.IsNotFound(err) {
 				continue
 			}
 
-			return false, err
+			return nil, err
 		}
 
 		logger.Info("Orphan object exists", "obj", cpy)
-		return true, nil
+		orphans = append(orphans, cpy)
 	}
 
-	return false, nil
+	return orphans, nil
 }
 
-// CrUpdate sets given phase on the CR and updates it in the cluster
+// CrUpdate sets given phase on the CR and writes its status to the cluster. During Reconcile the status is written once
+// the reconciliation finishes
 func (r *Reconciler) CrUpdate(phase sdkapi.Phase, cr runtime.Object) error {
-	r.crManager.Status(cr).Phase = phase
-	return r.client.Update(context.TODO(), cr)
+	status := r.crManager.Status(cr)
+	previousPhase := status.Phase
+	status.Phase = phase
+	if batch, ok := r.statusBatch(cr); ok {
+		batch.dirty = true
+		return nil
+	}
+	if err := r.patchCrStatus(cr, nil); err != nil {
+		return err
+	}
+	r.recordPhaseEvent(cr, previousPhase, phase)
+	r.recordStatusMetrics(cr)
+	return nil
 }
 
 // CrSetVersion sets version and phase on the CR object
@@ -433,42 +668,56 @@ func (r *Reconciler) ReconcileError(cr runtime.Object, message string) (reconcil
 	return reconcile.Result{}, nil
 }
 
-// CheckDegraded checks whether the deployment is degraded and updates CR status conditions accordingly
-func (r *Reconciler) CheckDegraded(logger logr.Logger, cr runtime.Object) (bool, error) {
-	degraded := false
+// failedWithReason checks whether the CR is in the Error phase because of any of given reasons
+func failedWithReason(status *sdkapi.Status, reasons ...string) bool {
+	if status.Phase != sdkapi.PhaseError {
+		return false
+	}
+	for _, condition := range status.Conditions {
+		for _, reason := range reasons {
+			if condition.Reason == reason {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	deployments, err := r.GetAllDeployments(cr)
+// CheckDegraded checks whether any of the managed resources is not ready and updates CR status conditions accordingly
+func (r *Reconciler) CheckDegraded(logger logr.Logger, cr runtime.Object) (bool, error) {
+	unready, err := r.CheckReadiness(cr)
 	if err != nil {
 		return true, err
 	}
+	degraded := len(unready) > 0
 
-	for _, deployment := range deployments {
-		key := client.ObjectKey{Namespace: deployment.Namespace, Name: deployment.Name}
-
-		if err = r.client.Get(context.TODO(), key, deployment); err != nil {
-			return true, err
-		}
-
-		if !sdk.CheckDeploymentReady(deployment) {
-			degraded = true
-			break
-		}
+	for _, resource := range unready {
+		logger.Info("Resource not ready", "resource", resource.String())
 	}
-
 	logger.Info("Degraded check", "Degraded", degraded)
 
 	// If deployed and degraded, mark degraded, otherwise we are still deploying or not degraded.
 	status := r.status(cr)
 	if degraded && status.Phase == sdkapi.PhaseDeployed {
 		conditions.SetStatusCondition(&status.Conditions, conditions.Condition{
-			Type:   conditions.ConditionDegraded,
-			Status: corev1.ConditionTrue,
+			Type:    conditions.ConditionDegraded,
+			Status:  corev1.ConditionTrue,
+			Reason:  resourcesNotReadyReason,
+			Message: unreadyMessage(unready),
 		})
 	} else {
 		conditions.SetStatusCondition(&status.Conditions, conditions.Condition{
 			Type:   conditions.ConditionDegraded,
 			Status: corev1.ConditionFalse,
 		})
+		if degraded && conditions.IsStatusConditionTrue(status.Conditions, conditions.ConditionProgressing) {
+			conditions.SetStatusCondition(&status.Conditions, conditions.Condition{
+				Type:    conditions.ConditionProgressing,
+				Status:  corev1.ConditionTrue,
+				Reason:  resourcesNotReadyReason,
+				Message: unreadyMessage(unready),
+			})
+		}
 	}
 
 	logger.Info("Finished degraded check", "conditions", status.Conditions)
@@ -517,15 +766,19 @@ func (r *Reconciler) WatchCR() error {
 
 // InvokeCallbacks executes callbacks registered
 func (r *Reconciler) InvokeCallbacks(l logr.Logger, cr runtime.Object, s callbacks.ReconcileState, desiredObj, currentObj runtime.Object) error {
+	defer observeCallbackDuration(s, time.Now())
 	return r.callbackDispatcher.InvokeCallbacks(l, cr, s, desiredObj, currentObj)
 }
 
 // WatchResourceTypes registers watches for given resources types
 func (r *Reconciler) WatchResourceTypes(resources ...runtime.Object) error {
-	typeSet := map[reflect.Type]bool{}
+	typeSet := map[schema.GroupVersionKind]bool{}
 
 	for _, resource := range resources {
-		t := reflect.TypeOf(resource)
+		t, err := apiutil.GVKForObject(resource, r.scheme)
+		if err != nil {
+			return err
+		}
 		if typeSet[t] {
 			continue
 		}
@@ -545,6 +798,14 @@ func (r *Reconciler) WatchResourceTypes(resources ...runtime.Object) error {
 			return err
 		}
 
+		// resources the namespaced CR cannot own are mapped to it by the owner annotation
+		if r.ownerAnnotation != "" {
+			annotationHandler := &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.annotatedOwnerRequests)}
+			if err := r.controller.Watch(&source.Kind{Type: resource}, annotationHandler, predicates...); err != nil {
+				return err
+			}
+		}
+
 		r.log.Info("Watching", "type", t)
 
 		typeSet[t] = true
@@ -571,101 +832,130 @@ func (r *Reconciler) CheckUpgrade(logger logr.Logger, cr runtime.Object, targetV
 	}
 
 	deploying := status.Phase == sdkapi.PhaseDeploying
-	isUpgrade, err := ShouldTakeUpdatePath(targetVersion, status.ObservedVersion, deploying)
-	if err != nil {
-		logger.Error(err, "", "current", status.ObservedVersion, "target", targetVersion)
-		return err
+	downgrade := !deploying && IsDowngrade(targetVersion, status.ObservedVersion)
+	if downgrade {
+		policy, err := r.downgradePolicy(cr)
+		if err != nil {
+			return err
+		}
+		if policy != DowngradePolicyAllow {
+			logger.Error(fmt.Errorf("operator downgraded, will not reconcile"), "", "current", status.ObservedVersion, "target", targetVersion)
+			refusedDowngrades.Inc()
+			return r.refuseVersionChange(cr, DowngradeRefusedReason, fmt.Sprintf("Refused to downgrade from version %s to %s", status.ObservedVersion, targetVersion))
+		}
+	}
+
+	isUpdate := shouldTakeUpdatePath(targetVersion, status.ObservedVersion, deploying)
+	if isUpdate && !downgrade {
+		if err := r.upgradeGraph.CheckUpgrade(status.ObservedVersion, targetVersion); err != nil {
+			logger.Error(err, "", "current", status.ObservedVersion, "target", targetVersion)
+			return r.refuseVersionChange(cr, UpgradePathRefusedReason, fmt.Sprintf("Refused to upgrade: %v", err))
+		}
 	}
 
-	if isUpgrade && status.Phase != sdkapi.PhaseUpgrading {
-		logger.Info("Observed version is not target version. Begin upgrade", "Observed version ", status.ObservedVersion, "TargetVersion", targetVersion)
-		sdk.MarkCrUpgradeHealingDegraded(status, "UpgradeStarted", fmt.Sprintf("Started upgrade to version %s", targetVersion))
+	if isUpdate && status.Phase != sdkapi.PhaseUpgrading {
+		status.LastMigration = ""
+		startUpgradeProgress(status, status.ObservedVersion, targetVersion)
+		if downgrade {
+			logger.Info("Target version is lower than observed version. Begin downgrade", "Observed version ", status.ObservedVersion, "TargetVersion", targetVersion)
+			sdk.MarkCrUpgradeHealingDegraded(status, DowngradeStartedReason, fmt.Sprintf("Started downgrade to version %s", targetVersion))
+		} else {
+			logger.Info("Observed version is not target version. Begin upgrade", "Observed version ", status.ObservedVersion, "TargetVersion", targetVersion)
+			sdk.MarkCrUpgradeHealingDegraded(status, "UpgradeStarted", fmt.Sprintf("Started upgrade to version %s", targetVersion))
+		}
 		if err := r.CrUpdate(sdkapi.PhaseUpgrading, cr); err != nil {
 			return err
 		}
+		if downgrade {
+			r.recordEvent(cr, corev1.EventTypeNormal, DowngradeStartedReason, "Started downgrade from version %s to %s", status.ObservedVersion, targetVersion)
+		} else {
+			r.recordEvent(cr, corev1.EventTypeNormal, UpgradeStartedReason, "Started upgrade from version %s to %s", status.ObservedVersion, targetVersion)
+		}
 	}
 
 	return nil
 }
 
+// refuseVersionChange reports the refused version change in the CR Degraded condition and returns the error to be returned
+// from reconciliation
+func (r *Reconciler) refuseVersionChange(cr runtime.Object, reason, message string) error {
+	r.recordEvent(cr, corev1.EventTypeWarning, reason, "%s", message)
+	conditions.SetStatusCondition(&r.status(cr).Conditions, conditions.Condition{
+		Type:    conditions.ConditionDegraded,
+		Status:  corev1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	})
+	return fmt.Errorf("%s", message)
+}
+
 // CleanupUnusedResources removes unused resources
 func (r *Reconciler) CleanupUnusedResources(logger logr.Logger, cr controllerutil.Object) error {
 	//Iterate over installed resources of
 	//Deployment/CRDs/Services etc and delete all resources that
 	//do not exist in current version
 
-	desiredResources, err := r.crManager.GetAllResources(cr)
+	unusedObjs, goneKeys, err := r.unusedResources(logger, cr)
 	if err != nil {
 		return err
 	}
 
-	listTypes := r.crManager.GetDependantResourcesListObjects()
-
-	ls, err := labels.Parse(r.createVersionLabel)
-	if err != nil {
-		return err
-	}
+	for _, observedObj := range unusedObjs {
+		observedMetaObj := observedObj.(metav1.Object)
+		key, err := r.resourceKey(observedObj)
+		if err != nil {
+			return err
+		}
 
-	for _, lt := range listTypes {
-		lo := &client.ListOptions{LabelSelector: ls}
+		//Invoke pre delete callback
+		if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePreDelete, nil, observedObj); err != nil {
+			return err
+		}
 
-		if err := r.client.List(context.TODO(), lt, lo); err != nil {
-			logger.Error(err, "Error listing resources")
+		logger.Info("Deleting  ", "type", key.gvk, "Name", observedMetaObj.GetName())
+		err = r.client.Delete(context.TODO(), observedObj, &client.DeleteOptions{
+			PropagationPolicy: &[]metav1.DeletionPropagation{metav1.DeletePropagationForeground}[0],
+		})
+		if err != nil && !errors.IsNotFound(err) {
 			return err
 		}
 
-		sv := reflect.ValueOf(lt).Elem()
-		iv := sv.FieldByName("Items")
+		r.recordResourceEvent(cr, ResourceDeletedReason, "Deleted", observedObj)
+		r.countResourceOperation(observedObj, resourceDeleted)
 
-		for i := 0; i < iv.Len(); i++ {
-			found := false
-			observedObj := iv.Index(i).Addr().Interface().(runtime.Object)
-			observedMetaObj := observedObj.(metav1.Object)
+		//invoke post delete callback
+		if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostDelete, nil, observedObj); err != nil {
+			return err
+		}
+		goneKeys = append(goneKeys, key)
+	}
 
-			for _, desiredObj := range desiredResources {
-				if sdk.SameResource(observedObj, desiredObj) {
-					found = true
-					break
-				}
-			}
+	return r.removeFromInventory(cr, goneKeys)
+}
 
-			if !found && metav1.IsControlledBy(observedMetaObj, cr) {
-				//Invoke pre delete callback
-				if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePreDelete, nil, observedObj); err != nil {
-					return err
-				}
-
-				logger.Info("Deleting  ", "type", reflect.TypeOf(observedObj), "Name", observedMetaObj.GetName())
-				err = r.client.Delete(context.TODO(), observedObj, &client.DeleteOptions{
-					PropagationPolicy: &[]metav1.DeletionPropagation{metav1.DeletePropagationForeground}[0],
-				})
-				if err != nil && !errors.IsNotFound(err) {
-					return err
-				}
-
-				//invoke post delete callback
-				if err = r.InvokeCallbacks(logger, cr, callbacks.ReconcileStatePostDelete, nil, observedObj); err != nil {
-					return err
-				}
-			}
+// unusedResources lists the resources owned by the CR, which do not exist in the current version, and the keys of the
+// resources recorded in the inventory that no longer exist
+func (r *Reconciler) unusedResources(logger logr.Logger, cr controllerutil.Object) ([]runtime.Object, []resourceKey, error) {
+	desiredResources, err := r.crManager.GetAllResources(cr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desiredKeys := make(map[resourceKey]bool)
+	for _, desiredObj := range desiredResources {
+		key, err := r.resourceKey(desiredObj)
+		if err != nil {
+			return nil, nil, err
 		}
+		desiredKeys[key] = true
 	}
 
-	return nil
+	return r.ownedResources(logger, cr, desiredKeys)
 }
 
 // ReconcileDelete executes Delete operation
 func (r *Reconciler) ReconcileDelete(logger logr.Logger, cr controllerutil.Object, finalizerName string) (reconcile.Result, error) {
-	i := -1
-	finalizers := cr.GetFinalizers()
-	for j, f := range finalizers {
-		if f == finalizerName {
-			i = j
-			break
-		}
-	}
-
-	if i < 0 {
+	if !hasFinalizer(cr, finalizerName) {
 		return reconcile.Result{}, nil
 	}
 
@@ -680,12 +970,22 @@ func (r *Reconciler) ReconcileDelete(logger logr.Logger, cr controllerutil.Objec
 		return reconcile.Result{}, err
 	}
 
-	finalizers = append(finalizers[0:i], finalizers[i+1:]...)
+	if err := r.deleteAnnotatedResources(logger, cr); err != nil {
+		return reconcile.Result{}, err
+	}
 
-	cr.SetFinalizers(finalizers)
 	if err := r.CrUpdate(sdkapi.PhaseDeleted, cr); err != nil {
 		return reconcile.Result{}, err
 	}
+	// the CR can be removed right after the finalizer
+	if err := r.flushCrStatus(cr); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err := r.removeCrFinalizer(cr, finalizerName); err != nil {
+		return reconcile.Result{}, err
+	}
+	r.deleteStatusMetrics(cr)
 
 	logger.Info("Finalizer complete")
 
@@ -694,8 +994,9 @@ func (r *Reconciler) ReconcileDelete(logger logr.Logger, cr controllerutil.Objec
 
 // CrInit initializes the CR and moves it to CR to  "Deploying" status
 func (r *Reconciler) CrInit(cr controllerutil.Object, operatorVersion string) error {
-	finalizers := append(cr.GetFinalizers(), r.finalizerName)
-	cr.SetFinalizers(finalizers)
+	if err := r.addCrFinalizer(cr, r.finalizerName); err != nil {
+		return err
+	}
 	status := r.status(cr)
 	status.OperatorVersion = operatorVersion
 	status.TargetVersion = operatorVersion
@@ -705,11 +1006,27 @@ func (r *Reconciler) CrInit(cr controllerutil.Object, operatorVersion string) er
 
 func (r *Reconciler) getCr(name types.NamespacedName) (controllerutil.Object, error) {
 	cr := r.crManager.Create()
-	crKey := client.ObjectKey{Namespace: "", Name: name.Name}
+	crKey := client.ObjectKey{Namespace: name.Namespace, Name: name.Name}
 	err := r.client.Get(context.TODO(), crKey, cr)
 	return cr, err
 }
 
+// resourceKey identifies a resource by its kind, namespace and name, regardless of its Go type
+type resourceKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+func (r *Reconciler) resourceKey(obj runtime.Object) (resourceKey, error) {
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return resourceKey{}, err
+	}
+	metaObj := obj.(metav1.Object)
+	return resourceKey{gvk: gvk, namespace: metaObj.GetNamespace(), name: metaObj.GetName()}, nil
+}
+
 func (r *Reconciler) status(object runtime.Object) *sdkapi.Status {
 	return r.crManager.Status(object)
 }
@@ -719,13 +1036,19 @@ func (r *Reconciler) setLastAppliedConfiguration(obj metav1.Object) error {
 }
 
 func (r *Reconciler) completeUpgrade(logger logr.Logger, cr controllerutil.Object, operatorVersion string) error {
+	status := r.status(cr)
+	if err := r.runMigrations(logger, cr, status.ObservedVersion, operatorVersion); err != nil {
+		return err
+	}
+
 	if err := r.CleanupUnusedResources(logger, cr); err != nil {
 		return err
 	}
 
-	status := r.status(cr)
 	previousVersion := status.ObservedVersion
 	status.ObservedVersion = operatorVersion
+	observeUpgradeDuration(status)
+	r.finishUpgradeProgress(status, sdkapi.UpgradeSucceeded)
 
 	sdk.MarkCrHealthyMessage(status, "DeployCompleted", "Deployment Completed")
 	if err := r.CrUpdate(sdkapi.PhaseDeployed, cr); err != nil {
@@ -733,6 +1056,7 @@ func (r *Reconciler) completeUpgrade(logger logr.Logger, cr controllerutil.Objec
 	}
 
 	logger.Info("Successfully finished Upgrade and entered Deployed state", "from version", previousVersion, "to version", status.ObservedVersion)
+	r.recordEvent(cr, corev1.EventTypeNormal, UpgradeCompletedReason, "Completed upgrade from version %s to %s", previousVersion, status.ObservedVersion)
 
 	return nil
 }
@@ -2,8 +2,10 @@ package reconciler_test
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -11,7 +13,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/tests/mocks"
@@ -19,6 +23,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 
 	"github.com/go-logr/logr"
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
 	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
 	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/callbacks"
 	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/reconciler"
@@ -28,12 +33,16 @@ import (
 	. "github.com/onsi/gomega"
 	v1 "github.com/openshift/custom-resource-status/conditions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	realClient "sigs.k8s.io/controller-runtime/pkg/client"
 	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -147,6 +156,23 @@ var _ = Describe("Reconciler", func() {
 				Expect(args.config.Finalizers).Should(HaveLen(1))
 			})
 
+			It("should report unready resources in conditions", func() {
+				args := createArgs(version)
+				doReconcile(args)
+
+				progressing := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionProgressing)
+				Expect(progressing.Reason).To(Equal("ResourcesNotReady"))
+				Expect(progressing.Message).To(ContainSubstring("Deployment %s/%s: 0 of 1 replicas ready", testcr.Namespace, testcr.OperatorDeploymentName))
+
+				Expect(setDeploymentsReady(args)).To(BeTrue())
+				setDeploymentsDegraded(args)
+
+				degraded := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionDegraded)
+				Expect(degraded.Status).To(Equal(corev1.ConditionTrue))
+				Expect(degraded.Reason).To(Equal("ResourcesNotReady"))
+				Expect(degraded.Message).To(Equal(fmt.Sprintf("1 resources not ready: Deployment %s/%s: 0 of 1 replicas ready", testcr.Namespace, testcr.OperatorDeploymentName)))
+			})
+
 			It("should become ready", func() {
 				args := createArgs(version)
 				doReconcile(args)
@@ -277,6 +303,221 @@ var _ = Describe("Reconciler", func() {
 			Entry("decreasing  semver no prefix", "1.10.0", "1.9.5"),
 		)
 
+		It("should refuse upgrade skipping mandatory version", func() {
+			graph := reconciler.NewUpgradeGraph()
+			Expect(graph.RequireVersion("v1.6.0")).To(Succeed())
+			args := createArgs("v1.5.0")
+			args.reconciler.WithUpgradeGraph(graph)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			args.version = "v1.7.0"
+			doReconcileError(args)
+
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeployed))
+			Expect(args.config.Status.ObservedVersion).Should(Equal("v1.5.0"))
+			degraded := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionDegraded)
+			Expect(degraded.Status).To(Equal(corev1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal(reconciler.UpgradePathRefusedReason))
+			Expect(degraded.Message).To(ContainSubstring("skips mandatory version 1.6.0"))
+
+			args.version = "v1.6.0"
+			doReconcile(args)
+			Expect(args.config.Status.ObservedVersion).Should(Equal("v1.6.0"))
+			Expect(v1.IsStatusConditionFalse(args.config.Status.Conditions, v1.ConditionDegraded)).To(BeTrue())
+		})
+
+		It("should resume migrations after the last completed one", func() {
+			calls := make(map[string]int)
+			failing := false
+			migrate := func(name string) reconciler.Migration {
+				return func(_ logr.Logger, _ controllerutil.Object) error {
+					calls[name]++
+					if name == "second" && failing {
+						return fmt.Errorf("migration failed")
+					}
+					return nil
+				}
+			}
+			args := createArgs("v1.9.5")
+			args.reconciler.
+				WithMigration("first", ">=1.10.0", migrate("first")).
+				WithMigration("second", ">=1.10.0", migrate("second")).
+				WithMigration("already-migrated", ">=1.9.0", migrate("already-migrated"))
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			setDeploymentsDegraded(args)
+			args.version = "v1.10.0"
+			doReconcile(args)
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseUpgrading))
+			Expect(calls).To(BeEmpty())
+
+			failing = true
+			deployment, err := getDeployment(args.client, getAllResources(args.config)[0].(*appsv1.Deployment))
+			Expect(err).ToNot(HaveOccurred())
+			deployment.Status.Replicas = *deployment.Spec.Replicas
+			deployment.Status.ReadyReplicas = deployment.Status.Replicas
+			Expect(args.client.Update(context.TODO(), deployment)).To(Succeed())
+			doReconcileError(args)
+
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseUpgrading))
+			Expect(args.config.Status.LastMigration).To(Equal("first"))
+
+			failing = false
+			doReconcile(args)
+
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeployed))
+			Expect(args.config.Status.LastMigration).To(Equal("second"))
+			Expect(calls).To(Equal(map[string]int{"first": 1, "second": 2}))
+		})
+
+		It("should return invalid migration version range from Build", func() {
+			args := createArgs(version)
+			args.reconciler.WithMigration("invalid", "not-a-range", func(_ logr.Logger, _ controllerutil.Object) error {
+				return nil
+			})
+
+			_, err := args.reconciler.Build()
+			Expect(err).To(MatchError(ContainSubstring("invalid version range of migration invalid")))
+			_, err = args.reconciler.Reconcile(reconcileRequest(args.config), args.version, log)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should record upgrade progress and bounded history", func() {
+			args := createArgs("v1.9.5")
+			args.reconciler.WithUpgradeHistoryLimit(1)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+			Expect(args.config.Status.UpgradeHistory).To(BeEmpty())
+
+			for _, version := range []string{"v1.10.0", "v1.11.0"} {
+				setDeploymentsDegraded(args)
+				previousVersion := args.version
+				args.version = version
+				doReconcile(args)
+
+				progress := args.config.Status.UpgradeInProgress
+				Expect(progress).ToNot(BeNil())
+				Expect(progress.FromVersion).To(Equal(previousVersion))
+				Expect(progress.ToVersion).To(Equal(version))
+				Expect(progress.StartTime.IsZero()).To(BeFalse())
+				Expect(progress.UpgradedResources).To(BeEquivalentTo(1))
+				Expect(progress.TotalResources).To(BeEquivalentTo(1))
+
+				Expect(setDeploymentsReady(args)).To(BeTrue())
+
+				Expect(args.config.Status.UpgradeInProgress).To(BeNil())
+				Expect(args.config.Status.UpgradeHistory).To(HaveLen(1))
+				record := args.config.Status.UpgradeHistory[0]
+				Expect(record.FromVersion).To(Equal(previousVersion))
+				Expect(record.ToVersion).To(Equal(version))
+				Expect(record.Result).To(Equal(sdkapi.UpgradeSucceeded))
+				Expect(record.FinishTime.Before(&record.StartTime)).To(BeFalse())
+			}
+		})
+
+		It("should count resources upgraded by previous passes in upgrade progress", func() {
+			args := createArgs("v1.9.5")
+			c := &failingClient{Client: args.client}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+			setDeploymentsDegraded(args)
+			modifyDeployment := func() {
+				deployment, err := getDeployment(args.client, operatorDeployment())
+				Expect(err).ToNot(HaveOccurred())
+				deployment.Spec.Template.Spec.Containers[0].Env[0].Value = "MODIFIED"
+				Expect(args.client.Update(context.TODO(), deployment)).To(Succeed())
+			}
+
+			// the upgrade updates the deployment
+			modifyDeployment()
+			args.version = "v1.10.0"
+			doReconcile(args)
+			Expect(args.config.Status.UpgradeInProgress.UpgradedResources).To(BeEquivalentTo(1))
+
+			modifyDeployment()
+			c.err = fmt.Errorf("update denied")
+			doReconcileError(args)
+
+			config, err := getConfig(args.client, args.config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Status.Phase).To(Equal(sdkapi.PhaseUpgrading))
+			Expect(config.Status.UpgradeInProgress.UpgradedResources).To(BeEquivalentTo(1))
+			Expect(config.Status.UpgradeInProgress.TotalResources).To(BeEquivalentTo(1))
+		})
+
+		It("should not read the resources again to record upgrade progress", func() {
+			args := createArgs("v1.9.5")
+			c := &readsClient{Client: args.client, gets: map[string]int{}, lists: map[string]int{}}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+			setDeploymentsDegraded(args)
+			c.gets = map[string]int{}
+			doReconcile(args)
+			deploymentGets := c.gets["*v1.Deployment"]
+			c.gets = map[string]int{}
+
+			args.version = "v1.10.0"
+			doReconcile(args)
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseUpgrading))
+			Expect(args.config.Status.UpgradeInProgress.UpgradedResources).To(Equal(args.config.Status.UpgradeInProgress.TotalResources))
+			Expect(c.gets["*v1.Deployment"]).To(Equal(deploymentGets))
+		})
+
+		It("should report refused downgrade in conditions", func() {
+			args := createArgs("v1.10.0")
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			args.version = "v1.9.5"
+			doReconcileError(args)
+
+			degraded := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionDegraded)
+			Expect(degraded.Status).To(Equal(corev1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal(reconciler.DowngradeRefusedReason))
+			Expect(degraded.Message).To(Equal("Refused to downgrade from version v1.10.0 to v1.9.5"))
+		})
+
+		DescribeTable("should downgrade when allowed", func(annotations map[string]string, policy reconciler.DowngradePolicy) {
+			args := createArgs("v1.10.0")
+			args.reconciler.WithDowngradePolicy(policy).WithDowngradePolicyAnnotation("downgrade-policy")
+			args.config.Annotations = annotations
+			Expect(args.client.Update(context.TODO(), args.config)).To(Succeed())
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			setDeploymentsDegraded(args)
+			args.version = "v1.9.5"
+			doReconcile(args)
+
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseUpgrading))
+			progressing := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionProgressing)
+			Expect(progressing.Status).To(Equal(corev1.ConditionTrue))
+
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeployed))
+			Expect(args.config.Status.ObservedVersion).Should(Equal("v1.9.5"))
+		},
+			Entry("by reconciler", nil, reconciler.DowngradePolicyAllow),
+			Entry("by annotation", map[string]string{"downgrade-policy": "Allow"}, reconciler.DowngradePolicyRefuse),
+		)
+
+		It("should fail on invalid downgrade policy", func() {
+			args := createArgs("v1.10.0")
+			args.reconciler.WithDowngradePolicyAnnotation("downgrade-policy")
+			args.config.Annotations = map[string]string{"downgrade-policy": "Sometimes"}
+			Expect(args.client.Update(context.TODO(), args.config)).To(Succeed())
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			args.version = "v1.9.5"
+			doReconcileError(args)
+		})
+
 	})
 
 	DescribeTable("Restores objects on upgrade", func(modify modifyResource, tomodify isModifySubject, upgraded isUpgraded) {
@@ -561,183 +802,1749 @@ var _ = Describe("Reconciler", func() {
 			}),
 	)
 
-	Describe("Config CR deletion during upgrade", func() {
-		It("should delete CR if it is marked for deletion and not begin upgrade flow", func() {
-			newVersion := "v0.0.2"
-			prevVersion := "v0.0.1"
+	Describe("Server-side apply", func() {
+		const fieldManager = "test-operator"
 
-			args := createArgs(newVersion)
+		It("should create resources with apply patches", func() {
+			args := createArgs(version)
+			c := &applyClient{Client: args.client}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController).WithServerSideApply(fieldManager)
 			doReconcile(args)
 
-			//set deployment to ready
-			isReady := setDeploymentsReady(args)
-			Expect(isReady).Should(Equal(true))
+			Expect(c.fieldManagers).To(HaveLen(len(getAllResources(args.config))))
+			for _, fm := range c.fieldManagers {
+				Expect(fm).To(Equal(fieldManager))
+			}
 
-			//verify on int version is set
-			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeployed))
+			for _, r := range getAllResources(args.config) {
+				obj, err := getObject(args.client, r)
+				Expect(err).ToNot(HaveOccurred())
+				metaObj := obj.(metav1.Object)
+				Expect(metaObj.GetAnnotations()).ToNot(HaveKey("last-applied-config"))
+				Expect(metaObj.GetLabels()).To(HaveKeyWithValue(createVersionLabel, version))
+				Expect(metav1.IsControlledBy(metaObj, args.config)).To(BeTrue())
+			}
+		})
 
-			//Modify CRD to be of previousVersion
-			_ = args.reconciler.CrSetVersion(args.config, prevVersion)
-			//mark CR for deletion
-			args.config.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
-			err := args.client.Update(context.TODO(), args.config)
-			Expect(err).ToNot(HaveOccurred())
+		It("should not apply unchanged resources", func() {
+			args := createArgs(version)
+			c := &applyClient{Client: args.client}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController).WithServerSideApply(fieldManager)
+			doReconcile(args)
+			c.fieldManagers = nil
 
 			doReconcile(args)
 
-			//verify the version cr is deleted and upgrade hasn't started
-			Expect(args.config.Status.OperatorVersion).Should(Equal(prevVersion))
-			Expect(args.config.Status.ObservedVersion).Should(Equal(prevVersion))
-			Expect(args.config.Status.TargetVersion).Should(Equal(prevVersion))
-			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeleted))
+			Expect(c.fieldManagers).To(BeEmpty())
 		})
 
-		It("should delete CR if it is marked for deletion during upgrade flow", func() {
-			newVersion := "v0.0.2"
-			prevVersion := "v0.0.1"
-
-			args := createArgs(newVersion)
+		It("should restore modified resources", func() {
+			args := createArgs(version)
+			c := &applyClient{Client: args.client}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController).WithServerSideApply(fieldManager)
 			doReconcile(args)
-			setDeploymentsReady(args)
-
-			//verify on int version is set
-			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeployed))
+			c.fieldManagers = nil
 
-			//Modify CRD to be of previousVersion
-			_ = args.reconciler.CrSetVersion(args.config, prevVersion)
-			err := args.client.Update(context.TODO(), args.config)
+			deployment, err := getDeployment(args.client, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: testcr.Namespace, Name: testcr.OperatorDeploymentName}})
 			Expect(err).ToNot(HaveOccurred())
-			setDeploymentsDegraded(args)
+			deployment.Spec.Template.Spec.Containers[0].Env = nil
+			Expect(args.client.Update(context.TODO(), deployment)).To(Succeed())
 
-			//begin upgrade
 			doReconcile(args)
 
-			//mark CR for deletion
-			args.config.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
-			err = args.client.Update(context.TODO(), args.config)
+			Expect(c.fieldManagers).To(HaveLen(1))
+			deployment, err = getDeployment(args.client, deployment)
 			Expect(err).ToNot(HaveOccurred())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Env).To(HaveLen(1))
+			Expect(deployment.GetLabels()).To(HaveKeyWithValue("update-version", version))
+		})
+	})
+
+	Describe("Unstructured resources", func() {
+		It("should manage unstructured resources next to typed ones", func() {
+			args := createArgs(version)
+			crManager := &unstructuredCrManager{withService: true}
+			args.client = &typedStorageClient{Client: args.client}
+			args.reconciler = reconciler.NewReconciler(crManager, log, args.client, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController)
 
 			doReconcile(args)
 
-			//set deployment to ready
-			isReady := setDeploymentsReady(args)
-			Expect(isReady).Should(Equal(false))
+			Expect(args.mockController.WatchCalls).To(HaveLen(3))
+			resources, err := crManager.GetAllResources(args.config)
+			Expect(err).ToNot(HaveOccurred())
+			for _, r := range resources {
+				obj, err := getObject(args.client, r)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(obj.(metav1.Object).GetLabels()).To(HaveKeyWithValue(createVersionLabel, version))
+			}
 
 			doReconcile(args)
-			//verify the version cr is marked as deleted
-			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeleted))
+
+			crManager.withService = false
+			err = args.reconciler.CleanupUnusedResources(log, args.config)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = getObject(args.client, unstructuredService())
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			_, err = getObject(args.client, unstructuredConfigMap())
+			Expect(err).ToNot(HaveOccurred())
 		})
 	})
-})
 
-func getConfig(c realClient.Client, cr *testcr.Config) (*testcr.Config, error) {
-	result, err := getObject(c, cr)
-	if err != nil {
-		return nil, err
-	}
-	return result.(*testcr.Config), nil
-}
+	Describe("Rollout waves", func() {
+		It("should deploy next wave only when the previous one is ready", func() {
+			args := createArgs(version)
+			crManager := &wavesCrManager{}
+			args.reconciler = reconciler.NewReconciler(crManager, log, args.client, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController).
+				WithRolloutWaves(rolloutWaveAnnotation)
 
-func createClient(scheme *runtime.Scheme, objs ...runtime.Object) realClient.Client {
-	return fakeClient.NewFakeClientWithScheme(scheme, objs...)
-}
+			doReconcile(args)
 
-func createReconciler(client realClient.Client, s *runtime.Scheme) *reconciler.Reconciler {
-	crManager := &testcr.ConfigCrManager{}
-	return reconciler.NewReconciler(crManager, log, client, callbackDispatcher, s, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName)
-}
+			Expect(args.config.Status.RolloutWave).ToNot(BeNil())
+			Expect(*args.config.Status.RolloutWave).To(BeEquivalentTo(0))
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			_, err := getObject(args.client, wavesConfigMap())
+			Expect(errors.IsNotFound(err)).To(BeTrue())
 
-func createConfig(name, uid string) *testcr.Config {
-	return &testcr.Config{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(uid)}, Status: testcr.ConfigStatus{}}
-}
+			Expect(setDeploymentsReady(args)).To(BeTrue())
 
-func (m *mockCallbackDispatcher) InvokeCallbacks(_ logr.Logger, cr interface{}, s callbacks.ReconcileState, desiredObj, currentObj runtime.Object) error {
-	return invokeCallbacks(cr, s, desiredObj, currentObj)
-}
+			Expect(*args.config.Status.RolloutWave).To(BeEquivalentTo(1))
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeployed))
+			_, err = getObject(args.client, wavesConfigMap())
+			Expect(err).ToNot(HaveOccurred())
+		})
 
-func (m *mockCallbackDispatcher) AddCallback(obj runtime.Object, cb callbacks.ReconcileCallback) {
-	addCallback(obj, cb)
-}
+		It("should not deploy next wave until the resources of the previous one exist", func() {
+			args := createArgs(version)
+			c := &droppingClient{Client: args.client, name: "first-wave"}
+			args.reconciler = reconciler.NewReconciler(&wavesCrManager{firstWaveConfigMap: true}, log, c, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController).
+				WithRolloutWaves(rolloutWaveAnnotation)
 
-func reconcileRequest(name string) reconcile.Request {
-	return reconcile.Request{NamespacedName: types.NamespacedName{Name: name}}
-}
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeFalse())
 
-func createArgs(version string) *args {
-	config := createConfig("test", "unique-id")
-	s := scheme.Scheme
-	err := testcr.AddToScheme(s)
-	if err != nil {
-		Fail(err.Error())
-	}
-	err = extv1.AddToScheme(s)
-	if err != nil {
-		Fail(err.Error())
-	}
-	client := createClient(s, config)
-	mockController := mocks.MockController{}
-	r := createReconciler(client, s)
-	r.WithController(&mockController)
+			Expect(*args.config.Status.RolloutWave).To(BeEquivalentTo(0))
+			_, err := getObject(args.client, wavesConfigMap())
+			Expect(errors.IsNotFound(err)).To(BeTrue())
 
-	return &args{
-		config:         config,
-		client:         client,
-		reconciler:     r,
-		version:        version,
-		mockController: &mockController,
-	}
-}
+			c.name = ""
+			doReconcile(args)
 
-func doReconcile(args *args) {
-	result, err := args.reconciler.Reconcile(reconcileRequest(args.config.Name), args.version, log)
-	Expect(err).ToNot(HaveOccurred())
-	Expect(result.Requeue).To(BeFalse())
+			Expect(*args.config.Status.RolloutWave).To(BeEquivalentTo(1))
+			_, err = getObject(args.client, wavesConfigMap())
+			Expect(err).ToNot(HaveOccurred())
+		})
 
-	args.config, err = getConfig(args.client, args.config)
-	Expect(err).ToNot(HaveOccurred())
-}
+		It("should fail on invalid wave", func() {
+			args := createArgs(version)
+			crManager := &wavesCrManager{wave: "first"}
+			args.reconciler = reconciler.NewReconciler(crManager, log, args.client, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController).
+				WithRolloutWaves(rolloutWaveAnnotation)
 
-func doReconcileError(args *args) {
-	result, err := args.reconciler.Reconcile(reconcileRequest(args.config.Name), args.version, log)
-	Expect(err).To(HaveOccurred())
-	Expect(result.Requeue).To(BeFalse())
+			doReconcileError(args)
+		})
+	})
 
-	args.config, err = getConfig(args.client, args.config)
-	Expect(err).ToNot(HaveOccurred())
-}
+	Describe("Readiness checks", func() {
+		It("should return error of checker for kind unknown to scheme from Build", func() {
+			args := createArgs(version)
+			args.reconciler.WithReadinessChecker(&unregisteredConfigMap{}, func(_ realClient.Client, _ runtime.Object) (bool, string, error) {
+				return true, "", nil
+			})
 
-func setDeploymentsReady(args *args) bool {
-	crManager := testcr.ConfigCrManager{}
-	resources, err := crManager.GetAllResources(args.config)
-	Expect(err).ToNot(HaveOccurred())
-	running := false
+			_, err := args.reconciler.Build()
+			Expect(err).To(HaveOccurred())
+			_, err = args.reconciler.Reconcile(reconcileRequest(args.config), args.version, log)
+			Expect(err).To(HaveOccurred())
+		})
 
-	for _, r := range resources {
-		d, ok := r.(*appsv1.Deployment)
-		if !ok {
-			continue
-		}
+		It("should not finish deployment until all resources are ready", func() {
+			args := createArgs(version)
+			configMapsReady := false
+			args.reconciler = reconciler.NewReconciler(&wavesCrManager{}, log, args.client, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController)
+			args.reconciler.WithReadinessChecker(&corev1.ConfigMap{}, func(_ realClient.Client, obj runtime.Object) (bool, string, error) {
+				return configMapsReady, "not populated", nil
+			})
 
-		Expect(running).To(BeFalse())
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeFalse())
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
 
-		d, err := getDeployment(args.client, d)
-		Expect(err).ToNot(HaveOccurred())
-		if d.Spec.Replicas != nil {
-			d.Status.Replicas = *d.Spec.Replicas
-			d.Status.ReadyReplicas = d.Status.Replicas
-			err = args.client.Update(context.TODO(), d)
+			unready, err := args.reconciler.CheckReadiness(args.config)
 			Expect(err).ToNot(HaveOccurred())
-		}
+			Expect(unready).To(HaveLen(1))
+			Expect(unready[0].GroupVersionKind.Kind).To(Equal("ConfigMap"))
+			Expect(unready[0].Name).To(Equal(wavesConfigMap().Name))
+			Expect(unready[0].Message).To(Equal("not populated"))
 
-		doReconcile(args)
+			configMapsReady = true
+			doReconcile(args)
 
-		if len(args.config.Status.Conditions) == 3 &&
-			v1.IsStatusConditionTrue(args.config.Status.Conditions, v1.ConditionAvailable) &&
-			v1.IsStatusConditionFalse(args.config.Status.Conditions, v1.ConditionProgressing) &&
-			v1.IsStatusConditionFalse(args.config.Status.Conditions, v1.ConditionDegraded) {
-			running = true
-		}
-	}
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeployed))
+			Expect(v1.IsStatusConditionFalse(args.config.Status.Conditions, v1.ConditionDegraded)).To(BeTrue())
+		})
+	})
+
+	Describe("Events", func() {
+		It("should record deployment and upgrade events", func() {
+			recorder := record.NewFakeRecorder(100)
+			args := createArgs("v1.9.5")
+			args.reconciler.WithEventRecorder(recorder)
+
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+			setDeploymentsDegraded(args)
+			args.version = "v1.10.0"
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			Expect(recordedEvents(recorder)).To(Equal([]string{
+				// phase changes are recorded once the status is written at the end of the reconciliation
+				fmt.Sprintf("Normal ResourceCreated Created Deployment %s/%s", testcr.Namespace, testcr.OperatorDeploymentName),
+				`Normal PhaseChanged Phase changed from "" to "Deploying"`,
+				`Normal PhaseChanged Phase changed from "Deploying" to "Deployed"`,
+				"Normal UpgradeStarted Started upgrade from version v1.9.5 to v1.10.0",
+				`Normal PhaseChanged Phase changed from "Deployed" to "Upgrading"`,
+				"Normal UpgradeCompleted Completed upgrade from version v1.9.5 to v1.10.0",
+				`Normal PhaseChanged Phase changed from "Upgrading" to "Deployed"`,
+			}))
+		})
+
+		It("should record downgrade refusal", func() {
+			recorder := record.NewFakeRecorder(100)
+			args := createArgs("v1.10.0")
+			args.reconciler.WithEventRecorder(recorder)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+			recordedEvents(recorder)
+
+			args.version = "v1.9.5"
+			doReconcileError(args)
+
+			Expect(recordedEvents(recorder)).To(ContainElement("Warning DowngradeRefused Refused to downgrade from version v1.10.0 to v1.9.5"))
+		})
+	})
+
+	Describe("Metrics", func() {
+		It("should expose CR state and resource operations", func() {
+			deploymentLabels := map[string]string{"group": "apps", "version": "v1", "kind": "Deployment", "operation": "created"}
+			created := metricValue("lifecycle_operator_resource_operations_total", deploymentLabels)
+			args := createArgs(version)
+
+			doReconcile(args)
+
+			crLabels := map[string]string{"kind": "Config", "name": args.config.Name}
+			Expect(metricValue("lifecycle_operator_resource_operations_total", deploymentLabels)).To(Equal(created + 1))
+			Expect(metricValue("lifecycle_operator_cr_phase", withLabel(crLabels, "phase", "Deploying"))).To(Equal(1.0))
+
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			Expect(metricValue("lifecycle_operator_cr_phase", withLabel(crLabels, "phase", "Deploying"))).To(Equal(0.0))
+			Expect(metricValue("lifecycle_operator_cr_phase", withLabel(crLabels, "phase", "Deployed"))).To(Equal(1.0))
+			availableLabels := withLabel(withLabel(crLabels, "condition", "Available"), "status", "True")
+			Expect(metricValue("lifecycle_operator_cr_condition", availableLabels)).To(Equal(1.0))
+		})
+
+		It("should remove CR state of deleted CR", func() {
+			args := createArgs(version)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+			crLabels := map[string]string{"kind": "Config", "name": args.config.Name}
+			availableLabels := withLabel(withLabel(crLabels, "condition", "Available"), "status", "True")
+			Expect(metricValue("lifecycle_operator_cr_condition", availableLabels)).To(Equal(1.0))
+
+			args.config.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			Expect(args.client.Update(context.TODO(), args.config)).To(Succeed())
+			doReconcile(args)
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeleted))
+			Expect(metricValue("lifecycle_operator_cr_phase", withLabel(crLabels, "phase", "Deleted"))).To(Equal(0.0))
+			Expect(metricValue("lifecycle_operator_cr_condition", availableLabels)).To(Equal(0.0))
+		})
+
+		It("should count refused downgrades", func() {
+			refused := metricValue("lifecycle_operator_refused_downgrades_total", nil)
+			args := createArgs("v1.10.0")
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			args.version = "v1.9.5"
+			doReconcileError(args)
+
+			Expect(metricValue("lifecycle_operator_refused_downgrades_total", nil)).To(Equal(refused + 1))
+		})
+	})
+
+	Describe("Upgrade timeout", func() {
+		var (
+			args      *args
+			crManager *rollbackCrManager
+		)
+
+		startTimedOutUpgrade := func() {
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			setDeploymentsDegraded(args)
+			crManager.upgraded = true
+			args.version = "v1.10.0"
+			doReconcile(args)
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseUpgrading))
+
+			args.config.Status.UpgradeInProgress.StartTime = metav1.NewTime(time.Now().Add(-time.Hour))
+			Expect(args.client.Update(context.TODO(), args.config)).To(Succeed())
+			doReconcile(args)
+		}
+
+		BeforeEach(func() {
+			args = createArgs("v1.9.5")
+			crManager = &rollbackCrManager{}
+			args.reconciler = reconciler.NewReconciler(crManager, log, args.client, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController).
+				WithUpgradeTimeout(time.Minute)
+		})
+
+		It("should mark timed out upgrade failed", func() {
+			startTimedOutUpgrade()
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseError))
+			Expect(args.config.Status.ObservedVersion).To(Equal("v1.9.5"))
+			degraded := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionDegraded)
+			Expect(degraded.Status).To(Equal(corev1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal(reconciler.UpgradeTimedOutReason))
+			Expect(degraded.Message).To(Equal("Upgrade from version v1.9.5 to v1.10.0 did not complete within 1m0s"))
+			Expect(args.config.Status.UpgradeInProgress).To(BeNil())
+			Expect(args.config.Status.UpgradeHistory).To(HaveLen(1))
+			Expect(args.config.Status.UpgradeHistory[0].Result).To(Equal(sdkapi.UpgradeFailed))
+
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Env[0].Value).To(Equal("UPGRADED"))
+
+			doReconcile(args)
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseError))
+
+			args.version = "v1.10.1"
+			doReconcile(args)
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseUpgrading))
+		})
+
+		It("should roll back timed out upgrade when enabled", func() {
+			args.reconciler.WithUpgradeRollback("rollback-config")
+			startTimedOutUpgrade()
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseError))
+			degraded := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionDegraded)
+			Expect(degraded.Message).To(HaveSuffix("rolled back to version v1.9.5"))
+
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Env[0].Value).To(Equal("BAR"))
+			Expect(deployment.Annotations).ToNot(HaveKey("rollback-config"))
+			Expect(deployment.Labels).To(HaveKeyWithValue("update-version", "v1.9.5"))
+
+			_, err = getObject(args.client, upgradeConfigMap())
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			doReconcile(args)
+			deployment, err = getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Env[0].Value).To(Equal("BAR"))
+		})
+	})
+
+	Describe("Plan", func() {
+		It("should plan creation of missing resources without writes", func() {
+			args := createArgs(version)
+
+			plan, err := args.reconciler.Plan(args.config)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(plan.Creates).To(HaveLen(1))
+			Expect(plan.Creates[0].GroupVersionKind.Kind).To(Equal("Deployment"))
+			Expect(plan.Creates[0].Name).To(Equal(testcr.OperatorDeploymentName))
+			Expect(plan.Updates).To(BeEmpty())
+			Expect(plan.Deletes).To(BeEmpty())
+			_, err = getDeployment(args.client, operatorDeployment())
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should plan updates with patches and deletes of unused resources", func() {
+			args := createArgs(version)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			plan, err := args.reconciler.Plan(args.config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.IsEmpty()).To(BeTrue())
+
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			deployment.Spec.Template.Spec.Containers[0].Env[0].Value = "MODIFIED"
+			Expect(args.client.Update(context.TODO(), deployment)).To(Succeed())
+
+			unused := testcr.ResourceBuilder.CreateDeployment("unused-deployment", testcr.Namespace, "match-key", "match-value", "", int32(1), corev1.PodSpec{})
+			unused.Labels = map[string]string{createVersionLabel: version}
+			Expect(controllerutil.SetControllerReference(args.config, unused, scheme.Scheme)).To(Succeed())
+			Expect(args.client.Create(context.TODO(), unused)).To(Succeed())
+
+			plan, err = args.reconciler.Plan(args.config)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(plan.Creates).To(BeEmpty())
+			Expect(plan.Updates).To(HaveLen(1))
+			Expect(plan.Updates[0].Name).To(Equal(testcr.OperatorDeploymentName))
+			Expect(string(plan.Updates[0].Patch)).To(ContainSubstring(`"path":"/spec/template/spec/containers/0/env/0/value","value":"BAR"`))
+			Expect(plan.Deletes).To(HaveLen(1))
+			Expect(plan.Deletes[0].Name).To(Equal("unused-deployment"))
+
+			deployment, err = getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Env[0].Value).To(Equal("MODIFIED"))
+			_, err = getObject(args.client, unused)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("Pause", func() {
+		modifyDeployment := func(args *args, annotations map[string]string) {
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			deployment.Spec.Template.Spec.Containers[0].Env[0].Value = "MODIFIED"
+			for k, v := range annotations {
+				deployment.Annotations[k] = v
+			}
+			Expect(args.client.Update(context.TODO(), deployment)).To(Succeed())
+		}
+
+		deploymentEnvValue := func(args *args) string {
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			return deployment.Spec.Template.Spec.Containers[0].Env[0].Value
+		}
+
+		It("should not write managed resources of paused CR", func() {
+			args := createArgs(version)
+			args.reconciler.WithPauseAnnotation("paused")
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			args.config.Annotations = map[string]string{"paused": "true"}
+			Expect(args.client.Update(context.TODO(), args.config)).To(Succeed())
+			modifyDeployment(args, nil)
+			setDeploymentsDegraded(args)
+
+			Expect(deploymentEnvValue(args)).To(Equal("MODIFIED"))
+			paused := v1.FindStatusCondition(args.config.Status.Conditions, reconciler.ConditionPaused)
+			Expect(paused).ToNot(BeNil())
+			Expect(paused.Status).To(Equal(corev1.ConditionTrue))
+			Expect(paused.Reason).To(Equal(reconciler.PausedReason))
+			Expect(v1.IsStatusConditionTrue(args.config.Status.Conditions, v1.ConditionDegraded)).To(BeTrue())
+
+			args.config.Annotations = nil
+			Expect(args.client.Update(context.TODO(), args.config)).To(Succeed())
+			doReconcile(args)
+
+			Expect(deploymentEnvValue(args)).To(Equal("BAR"))
+			Expect(v1.FindStatusCondition(args.config.Status.Conditions, reconciler.ConditionPaused)).To(BeNil())
+		})
+
+		It("should not adopt orphans of paused CR", func() {
+			args := createArgs(version)
+			args.reconciler.WithPauseAnnotation("paused").WithOrphanPolicy(reconciler.OrphanPolicyAdopt)
+			args.config.Annotations = map[string]string{"paused": "true"}
+			Expect(args.client.Update(context.TODO(), args.config)).To(Succeed())
+			Expect(args.client.Create(context.TODO(), operatorDeployment())).To(Succeed())
+
+			doReconcile(args)
+
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deployment.OwnerReferences).To(BeEmpty())
+			Expect(deployment.Labels).ToNot(HaveKey(createVersionLabel))
+			Expect(args.config.Status.Phase).To(BeEmpty())
+			Expect(args.config.Finalizers).To(BeEmpty())
+			Expect(v1.IsStatusConditionTrue(args.config.Status.Conditions, reconciler.ConditionPaused)).To(BeTrue())
+
+			args.config.Annotations = nil
+			Expect(args.client.Update(context.TODO(), args.config)).To(Succeed())
+			doReconcile(args)
+
+			deployment, err = getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metav1.IsControlledBy(deployment, args.config)).To(BeTrue())
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			Expect(v1.FindStatusCondition(args.config.Status.Conditions, reconciler.ConditionPaused)).To(BeNil())
+		})
+
+		It("should not update opted out resources", func() {
+			args := createArgs(version)
+			args.reconciler.WithOptOutAnnotation("opt-out")
+			doReconcile(args)
+
+			modifyDeployment(args, map[string]string{"opt-out": "true"})
+			doReconcile(args)
+
+			Expect(deploymentEnvValue(args)).To(Equal("MODIFIED"))
+			plan, err := args.reconciler.Plan(args.config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.IsEmpty()).To(BeTrue())
+		})
+	})
+
+	Describe("CR status writes", func() {
+		It("should patch status subresource and retry on conflicts", func() {
+			args := createArgs(version)
+			c := &statusClient{Client: args.client}
+			c.beforeStatusPatch = func() {
+				config, err := getConfig(args.client, args.config)
+				Expect(err).ToNot(HaveOccurred())
+				config.Annotations = map[string]string{"edited": "true"}
+				Expect(args.client.Update(context.TODO(), config)).To(Succeed())
+			}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController)
+
+			doReconcile(args)
+
+			Expect(c.crUpdates).To(BeZero())
+			Expect(c.statusPatches).To(BeNumerically(">", 1))
+			Expect(args.config.Annotations).To(HaveKeyWithValue("edited", "true"))
+			Expect(args.config.Finalizers).To(ContainElement(finalizerName))
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			Expect(args.config.Status.OperatorVersion).To(Equal(version))
+		})
+
+		It("should write status once per reconcile", func() {
+			args := createArgs("v1.9.5")
+			c := &statusClient{Client: args.client}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+			setDeploymentsDegraded(args)
+
+			c.statusPatches = 0
+			args.version = "v1.10.0"
+			doReconcile(args)
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseUpgrading))
+			Expect(args.config.Status.UpgradeInProgress).ToNot(BeNil())
+			Expect(c.statusPatches).To(Equal(1))
+
+			c.statusPatches = 0
+			doReconcile(args)
+			Expect(c.statusPatches).To(BeZero())
+		})
+
+		It("should report status write error together with reconcile error", func() {
+			args := createArgs("v1.9.5")
+			failing := &failingClient{Client: args.client}
+			c := &statusClient{Client: failing}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			deployment.Spec.Template.Spec.Containers[0].Env[0].Value = "MODIFIED"
+			Expect(args.client.Update(context.TODO(), deployment)).To(Succeed())
+
+			failing.err = fmt.Errorf("update denied")
+			c.statusPatchErr = fmt.Errorf("status patch denied")
+			args.version = "v1.10.0"
+			_, err = args.reconciler.Reconcile(reconcileRequest(args.config), args.version, log)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("update denied"))
+			Expect(err.Error()).To(ContainSubstring("status patch denied"))
+		})
+	})
+
+	Describe("Resource write errors", func() {
+		var (
+			args *args
+			c    *failingClient
+		)
+
+		BeforeEach(func() {
+			args = createArgs(version)
+			c = &failingClient{Client: args.client}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController)
+			doReconcile(args)
+
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			deployment.Spec.Template.Spec.Containers[0].Env[0].Value = "MODIFIED"
+			Expect(args.client.Update(context.TODO(), deployment)).To(Succeed())
+		})
+
+		It("should retry conflicting updates", func() {
+			c.conflicts = 2
+
+			doReconcile(args)
+
+			Expect(c.conflicts).To(BeZero())
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Env[0].Value).To(Equal("BAR"))
+		})
+
+		It("should report failed resources in typed error", func() {
+			c.err = errors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, testcr.OperatorDeploymentName, fmt.Errorf("denied"))
+
+			_, err := args.reconciler.Reconcile(reconcileRequest(args.config), args.version, log)
+
+			var reconcileErrors reconciler.ReconcileErrors
+			Expect(goerrors.As(err, &reconcileErrors)).To(BeTrue())
+			Expect(reconcileErrors).To(HaveLen(1))
+			Expect(reconcileErrors[0].GroupVersionKind.Kind).To(Equal("Deployment"))
+			Expect(reconcileErrors[0].Namespace).To(Equal(testcr.Namespace))
+			Expect(reconcileErrors[0].Name).To(Equal(testcr.OperatorDeploymentName))
+			Expect(errors.IsForbidden(reconcileErrors[0].Err)).To(BeTrue())
+			Expect(err.Error()).To(HavePrefix("reconcile encountered 1 errors: Deployment " + testcr.Namespace + "/" + testcr.OperatorDeploymentName))
+		})
+	})
+
+	Describe("Concurrency", func() {
+		var (
+			args *args
+			c    *concurrencyClient
+		)
+
+		BeforeEach(func() {
+			args = createArgs(version)
+			c = &concurrencyClient{Client: args.client, failNames: map[string]bool{}}
+			args.reconciler = reconciler.NewReconciler(&manyCrManager{count: 12}, log, c, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController).
+				WithConcurrency(4)
+		})
+
+		It("should reconcile resources with bounded concurrency", func() {
+			doReconcile(args)
+
+			for i := 0; i < 12; i++ {
+				_, err := getObject(args.client, manyConfigMap(i))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(c.maxInFlight).To(BeNumerically(">", 1))
+			Expect(c.maxInFlight).To(BeNumerically("<=", 4))
+		})
+
+		It("should report errors in the order of the resources", func() {
+			c.failNames[manyConfigMap(8).Name] = true
+			c.failNames[manyConfigMap(3).Name] = true
+
+			_, err := args.reconciler.Reconcile(reconcileRequest(args.config), args.version, log)
+
+			var reconcileErrors reconciler.ReconcileErrors
+			Expect(goerrors.As(err, &reconcileErrors)).To(BeTrue())
+			Expect(reconcileErrors).To(HaveLen(2))
+			Expect(reconcileErrors[0].Name).To(Equal(manyConfigMap(3).Name))
+			Expect(reconcileErrors[1].Name).To(Equal(manyConfigMap(8).Name))
+		})
+
+		It("should refuse non-positive concurrency", func() {
+			Expect(func() { args.reconciler.WithConcurrency(0) }).To(Panic())
+		})
+	})
+
+	Describe("Read policy", func() {
+		var (
+			args     *args
+			cached   *readsClient
+			uncached *readsClient
+		)
+
+		BeforeEach(func() {
+			args = createArgs(version)
+			cached = &readsClient{Client: args.client, gets: map[string]int{}, lists: map[string]int{}}
+			uncached = &readsClient{Client: args.client, gets: map[string]int{}, lists: map[string]int{}}
+			args.reconciler = reconciler.NewReconciler(&clusterScopedCrManager{}, log, cached, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController).
+				WithUncachedClient(uncached)
+		})
+
+		It("should read kinds registered for uncached reads with uncached client", func() {
+			args.reconciler.WithUncachedReads(&appsv1.Deployment{})
+			doReconcile(args)
+			cached.gets, uncached.gets = map[string]int{}, map[string]int{}
+
+			doReconcile(args)
+
+			Expect(cached.gets).ToNot(HaveKey("*v1.Deployment"))
+			Expect(uncached.gets).To(HaveKey("*v1.Deployment"))
+			Expect(cached.gets).To(HaveKey("*v1.ClusterRole"))
+		})
+
+		It("should read cluster-scoped resources with uncached client", func() {
+			args.reconciler.WithUncachedClusterScopedReads()
+			doReconcile(args)
+			cached.gets, uncached.gets = map[string]int{}, map[string]int{}
+
+			doReconcile(args)
+
+			Expect(cached.gets).ToNot(HaveKey("*v1.ClusterRole"))
+			Expect(uncached.gets).To(HaveKey("*v1.ClusterRole"))
+			Expect(cached.gets).To(HaveKey("*v1.Deployment"))
+		})
+
+		It("should not list pods of unready resources by default", func() {
+			doReconcile(args)
+
+			Expect(uncached.lists).ToNot(HaveKey("*v1.PodList"))
+			Expect(cached.lists).ToNot(HaveKey("*v1.PodList"))
+		})
+
+		It("should list pods of unready resources with uncached client", func() {
+			args.reconciler.WithPodInspection()
+			doReconcile(args)
+
+			Expect(uncached.lists).To(HaveKey("*v1.PodList"))
+			Expect(cached.lists).ToNot(HaveKey("*v1.PodList"))
+		})
+
+		It("should fall back to uncached client for resources missing in cache", func() {
+			args.reconciler.WithUncachedFallback()
+			doReconcile(args)
+			cached.missing = true
+
+			doReconcile(args)
+
+			Expect(uncached.gets).To(HaveKey("*v1.Deployment"))
+			Expect(uncached.gets).To(HaveKey("*v1.ClusterRole"))
+		})
+
+		It("should not fall back to uncached client by default", func() {
+			doReconcile(args)
+			cached.missing = true
+			uncached.gets = map[string]int{}
+
+			doReconcileError(args)
+
+			Expect(uncached.gets).To(BeEmpty())
+		})
+	})
+
+	Describe("Namespaced CR", func() {
+		const ownerAnnotation = "owner"
+
+		var args *args
+
+		newReconciler := func() *reconciler.Reconciler {
+			return reconciler.NewReconciler(&clusterScopedCrManager{}, log, args.client, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController)
+		}
+
+		BeforeEach(func() {
+			args = createArgs(version)
+			args.config = createConfig("test", "unique-id")
+			args.config.Namespace = testcr.Namespace
+			args.client = createClient(scheme.Scheme, args.config)
+			args.reconciler = newReconciler().WithOwnerAnnotation(ownerAnnotation)
+		})
+
+		It("should own namespaced resources and annotate cluster-scoped ones", func() {
+			doReconcile(args)
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metav1.IsControlledBy(deployment, args.config)).To(BeTrue())
+
+			obj, err := getObject(args.client, clusterRole("cluster-scoped-role"))
+			Expect(err).ToNot(HaveOccurred())
+			role := obj.(*rbacv1.ClusterRole)
+			Expect(role.OwnerReferences).To(BeEmpty())
+			Expect(role.Annotations).To(HaveKeyWithValue(ownerAnnotation, testcr.Namespace+"/test"))
+		})
+
+		It("should watch resources by owner annotation", func() {
+			doReconcile(args)
+
+			var mapHandlers []*handler.EnqueueRequestsFromMapFunc
+			for _, call := range args.mockController.WatchCalls {
+				if mapHandler, ok := call.Eventhandler.(*handler.EnqueueRequestsFromMapFunc); ok {
+					mapHandlers = append(mapHandlers, mapHandler)
+				}
+			}
+			Expect(mapHandlers).ToNot(BeEmpty())
+
+			role := clusterRole("cluster-scoped-role")
+			role.Annotations = map[string]string{ownerAnnotation: testcr.Namespace + "/test"}
+			requests := mapHandlers[0].ToRequests.Map(handler.MapObject{Meta: role, Object: role})
+			Expect(requests).To(ConsistOf(reconcileRequest(args.config)))
+
+			Expect(mapHandlers[0].ToRequests.Map(handler.MapObject{Meta: clusterRole("other-role"), Object: clusterRole("other-role")})).To(BeEmpty())
+		})
+
+		It("should fail on cluster-scoped resources without owner annotation", func() {
+			args.reconciler = newReconciler()
+
+			doReconcileError(args)
+
+			_, err := getObject(args.client, clusterRole("cluster-scoped-role"))
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should remove unused annotated resources of the CR only", func() {
+			doReconcile(args)
+			unused := clusterRole("unused-role")
+			unused.Labels = map[string]string{createVersionLabel: version}
+			unused.Annotations = map[string]string{ownerAnnotation: testcr.Namespace + "/test"}
+			foreign := clusterRole("foreign-role")
+			foreign.Labels = map[string]string{createVersionLabel: version}
+			foreign.Annotations = map[string]string{ownerAnnotation: "other-namespace/test"}
+			Expect(args.client.Create(context.TODO(), unused)).To(Succeed())
+			Expect(args.client.Create(context.TODO(), foreign)).To(Succeed())
+
+			Expect(args.reconciler.CleanupUnusedResources(log, args.config)).To(Succeed())
+
+			_, err := getObject(args.client, unused)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			_, err = getObject(args.client, foreign)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = getObject(args.client, clusterRole("cluster-scoped-role"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should remove annotated resources when the CR is deleted", func() {
+			doReconcile(args)
+
+			args.config.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+			Expect(args.client.Update(context.TODO(), args.config)).To(Succeed())
+			doReconcile(args)
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeleted))
+			_, err := getObject(args.client, clusterRole("cluster-scoped-role"))
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			// namespaced resources are removed by the garbage collector
+			_, err = getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("Singleton", func() {
+		var (
+			args   *args
+			second *testcr.Config
+		)
+
+		BeforeEach(func() {
+			args = createArgs(version)
+			second = createConfig("second", "second-id")
+			second.CreationTimestamp = metav1.NewTime(time.Now().Add(time.Hour))
+			Expect(args.client.Create(context.TODO(), second)).To(Succeed())
+		})
+
+		reconcileSecond := func() reconcile.Result {
+			result, err := args.reconciler.Reconcile(reconcileRequest(second), args.version, log)
+			Expect(err).ToNot(HaveOccurred())
+			second, err = getConfig(args.client, second)
+			Expect(err).ToNot(HaveOccurred())
+			return result
+		}
+
+		It("should reject CRs other than the oldest one", func() {
+			args.reconciler.WithSingleton("")
+			doReconcile(args)
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+
+			result := reconcileSecond()
+
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+			Expect(second.Status.Phase).To(Equal(sdkapi.PhaseError))
+			degraded := v1.FindStatusCondition(second.Status.Conditions, v1.ConditionDegraded)
+			Expect(degraded).ToNot(BeNil())
+			Expect(degraded.Reason).To(Equal(reconciler.SingletonRejectedReason))
+			Expect(degraded.Message).To(ContainSubstring("test already exists"))
+			Expect(second.Finalizers).To(BeEmpty())
+		})
+
+		It("should reconcile rejected CR once the oldest one is removed", func() {
+			args.reconciler.WithSingleton("")
+			doReconcile(args)
+			reconcileSecond()
+			Expect(second.Status.Phase).To(Equal(sdkapi.PhaseError))
+
+			// the resources of the removed CR are removed by the garbage collector
+			Expect(args.client.Delete(context.TODO(), args.config)).To(Succeed())
+			Expect(args.client.Delete(context.TODO(), operatorDeployment())).To(Succeed())
+			reconcileSecond()
+
+			Expect(second.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			Expect(second.Finalizers).To(ContainElement(finalizerName))
+		})
+
+		It("should not list CRs once the CR is accepted", func() {
+			c := &readsClient{Client: args.client, gets: map[string]int{}, lists: map[string]int{}}
+			args.reconciler = createReconciler(c, scheme.Scheme).WithController(args.mockController).WithSingleton("")
+			doReconcile(args)
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			Expect(c.lists).To(HaveKey("*v1beta1.ConfigList"))
+			c.lists = map[string]int{}
+
+			doReconcile(args)
+
+			Expect(c.lists).ToNot(HaveKey("*v1beta1.ConfigList"))
+		})
+
+		It("should reject CRs other than the configured one", func() {
+			args.reconciler.WithSingleton("second")
+
+			doReconcile(args)
+			result := reconcileSecond()
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseError))
+			degraded := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionDegraded)
+			Expect(degraded).ToNot(BeNil())
+			Expect(degraded.Reason).To(Equal(reconciler.SingletonRejectedReason))
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(second.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+		})
+	})
+
+	Describe("Orphans", func() {
+		var (
+			args     *args
+			recorder *record.FakeRecorder
+			orphan   *appsv1.Deployment
+		)
+
+		BeforeEach(func() {
+			recorder = record.NewFakeRecorder(100)
+			args = createArgs(version)
+			args.reconciler.WithEventRecorder(recorder)
+			orphan = operatorDeployment()
+		})
+
+		reconcileWithOrphans := func() reconcile.Result {
+			Expect(args.client.Create(context.TODO(), orphan)).To(Succeed())
+			result, err := args.reconciler.Reconcile(reconcileRequest(args.config), args.version, log)
+			Expect(err).ToNot(HaveOccurred())
+			args.config, err = getConfig(args.client, args.config)
+			Expect(err).ToNot(HaveOccurred())
+			return result
+		}
+
+		expectFailed := func(reason string) {
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseError))
+			degraded := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionDegraded)
+			Expect(degraded).ToNot(BeNil())
+			Expect(degraded.Reason).To(Equal(reason))
+		}
+
+		It("should wait for orphans to be removed by default", func() {
+			result := reconcileWithOrphans()
+
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+			Expect(args.config.Status.Phase).To(BeEmpty())
+			progressing := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionProgressing)
+			Expect(progressing).ToNot(BeNil())
+			Expect(progressing.Reason).To(Equal(reconciler.WaitingForOrphansReason))
+			Expect(recordedEvents(recorder)).To(ContainElement(fmt.Sprintf("Normal WaitingForOrphans Waiting for resources to be removed: Deployment %s/%s", testcr.Namespace, testcr.OperatorDeploymentName)))
+
+			Expect(args.client.Delete(context.TODO(), orphan)).To(Succeed())
+			doReconcile(args)
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			Expect(args.config.Finalizers).To(ContainElement(finalizerName))
+		})
+
+		It("should not update the status while waiting for the same orphans", func() {
+			reconcileWithOrphans()
+			Expect(recordedEvents(recorder)).To(ContainElement(ContainSubstring("WaitingForOrphans")))
+			resourceVersion := args.config.ResourceVersion
+
+			result, err := args.reconciler.Reconcile(reconcileRequest(args.config), args.version, log)
+			Expect(err).ToNot(HaveOccurred())
+			args.config, err = getConfig(args.client, args.config)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+			Expect(args.config.ResourceVersion).To(Equal(resourceVersion))
+			Expect(recordedEvents(recorder)).To(BeEmpty())
+			progressing := v1.FindStatusCondition(args.config.Status.Conditions, v1.ConditionProgressing)
+			Expect(progressing).ToNot(BeNil())
+			Expect(progressing.Reason).To(Equal(reconciler.WaitingForOrphansReason))
+		})
+
+		It("should adopt orphans", func() {
+			args.reconciler.WithOrphanPolicy(reconciler.OrphanPolicyAdopt)
+
+			reconcileWithOrphans()
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			deployment, err := getDeployment(args.client, orphan)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metav1.IsControlledBy(deployment, args.config)).To(BeTrue())
+			Expect(deployment.Labels).To(HaveKeyWithValue(createVersionLabel, version))
+			Expect(deployment.Spec.Template.Spec.Containers).ToNot(BeEmpty())
+			Expect(recordedEvents(recorder)).To(ContainElement(fmt.Sprintf("Normal ResourceAdopted Adopted Deployment %s/%s", testcr.Namespace, testcr.OperatorDeploymentName)))
+		})
+
+		It("should adopt orphans controlled by a former instance of the CR", func() {
+			args.reconciler.WithOrphanPolicy(reconciler.OrphanPolicyAdopt)
+			former := createConfig(args.config.Name, "former-id")
+			Expect(controllerutil.SetControllerReference(former, orphan, scheme.Scheme)).To(Succeed())
+
+			reconcileWithOrphans()
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			deployment, err := getDeployment(args.client, orphan)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metav1.IsControlledBy(deployment, args.config)).To(BeTrue())
+			Expect(deployment.OwnerReferences).To(HaveLen(1))
+		})
+
+		It("should fail on orphans until they are removed", func() {
+			args.reconciler.WithOrphanPolicy(reconciler.OrphanPolicyFail)
+
+			result := reconcileWithOrphans()
+
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+			expectFailed(reconciler.OrphanResourcesReason)
+			Expect(recordedEvents(recorder)).To(ContainElement(fmt.Sprintf("Warning OrphanResources Resources already exist: Deployment %s/%s", testcr.Namespace, testcr.OperatorDeploymentName)))
+
+			Expect(args.client.Delete(context.TODO(), orphan)).To(Succeed())
+			doReconcile(args)
+
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeploying))
+			Expect(args.config.Finalizers).To(ContainElement(finalizerName))
+		})
+
+		It("should report orphans controlled by other owners", func() {
+			args.reconciler.WithOrphanPolicy(reconciler.OrphanPolicyAdopt)
+			other := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: testcr.Namespace, UID: "other-id"}}
+			Expect(controllerutil.SetControllerReference(other, orphan, scheme.Scheme)).To(Succeed())
+
+			result := reconcileWithOrphans()
+
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+			expectFailed(reconciler.ForeignOwnedResourcesReason)
+			deployment, err := getDeployment(args.client, orphan)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metav1.IsControlledBy(deployment, other)).To(BeTrue())
+			Expect(recordedEvents(recorder)).To(ContainElement(fmt.Sprintf("Warning ResourcesOwnedByOthers Resources controlled by other owners: Deployment %s/%s", testcr.Namespace, testcr.OperatorDeploymentName)))
+		})
+	})
+
+	Describe("Pruning", func() {
+		var (
+			args    *args
+			unused  *appsv1.Deployment
+			foreign *appsv1.Deployment
+		)
+
+		BeforeEach(func() {
+			args = createArgs(version)
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			unused = testcr.ResourceBuilder.CreateDeployment("unused-deployment", testcr.Namespace, "match-key", "match-value", "", int32(1), corev1.PodSpec{})
+			unused.Labels[createVersionLabel] = version
+			Expect(controllerutil.SetControllerReference(args.config, unused, scheme.Scheme)).To(Succeed())
+			Expect(args.client.Create(context.TODO(), unused)).To(Succeed())
+
+			foreign = testcr.ResourceBuilder.CreateDeployment("foreign-deployment", testcr.Namespace, "match-key", "match-value", "", int32(1), corev1.PodSpec{})
+			foreign.Labels[createVersionLabel] = version
+			Expect(args.client.Create(context.TODO(), foreign)).To(Succeed())
+		})
+
+		It("should keep unused resources until upgrade by default", func() {
+			doReconcile(args)
+
+			_, err := getDeployment(args.client, unused)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should prune unused resources controlled by the CR on reconcile", func() {
+			var states []callbacks.ReconcileState
+			invokeCallbacks = func(_ interface{}, s callbacks.ReconcileState, _ runtime.Object, currentObj runtime.Object) error {
+				if currentObj != nil && currentObj.(metav1.Object).GetName() == unused.Name {
+					states = append(states, s)
+				}
+				return nil
+			}
+			args.reconciler.WithPruning()
+
+			doReconcile(args)
+
+			_, err := getDeployment(args.client, unused)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			_, err = getDeployment(args.client, foreign)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(states).To(Equal([]callbacks.ReconcileState{callbacks.ReconcileStatePreDelete, callbacks.ReconcileStatePostDelete}))
+			Expect(args.config.Status.Phase).To(Equal(sdkapi.PhaseDeployed))
+		})
+
+		It("should report resources to be pruned without removing them", func() {
+			deletes, err := args.reconciler.PlanPruning(args.config)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deletes).To(HaveLen(1))
+			Expect(deletes[0].Name).To(Equal(unused.Name))
+			Expect(deletes[0].GroupVersionKind.Kind).To(Equal("Deployment"))
+			_, err = getDeployment(args.client, unused)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("Inventory", func() {
+		var (
+			args      *args
+			crManager *rollbackCrManager
+		)
+
+		BeforeEach(func() {
+			args = createArgs(version)
+			crManager = &rollbackCrManager{upgraded: true}
+			args.reconciler = reconciler.NewReconciler(crManager, log, args.client, callbackDispatcher, scheme.Scheme, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName).
+				WithController(args.mockController)
+		})
+
+		inventoryNames := func() []string {
+			var names []string
+			for _, resource := range args.config.Status.Inventory {
+				names = append(names, resource.Kind+"/"+resource.Name)
+			}
+			return names
+		}
+
+		It("should record applied resources", func() {
+			args.reconciler.WithInventory()
+
+			doReconcile(args)
+
+			Expect(args.config.Status.Inventory).To(ConsistOf(
+				sdkapi.ManagedResource{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: testcr.Namespace, Name: testcr.OperatorDeploymentName, OperatorVersion: version},
+				sdkapi.ManagedResource{Version: "v1", Kind: "ConfigMap", Namespace: testcr.Namespace, Name: "upgrade-only", OperatorVersion: version},
+			))
+		})
+
+		It("should prune resources of kinds missing in the dependant resource types", func() {
+			args.reconciler.WithInventory().WithPruning()
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			crManager.upgraded = false
+			doReconcile(args)
+
+			_, err := getObject(args.client, upgradeConfigMap())
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(inventoryNames()).To(Equal([]string{"Deployment/" + testcr.OperatorDeploymentName}))
+		})
+
+		It("should not prune resources of kinds missing in the dependant resource types without inventory", func() {
+			args.reconciler.WithPruning()
+			doReconcile(args)
+			Expect(setDeploymentsReady(args)).To(BeTrue())
+
+			crManager.upgraded = false
+			doReconcile(args)
+
+			_, err := getObject(args.client, upgradeConfigMap())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(args.config.Status.Inventory).To(BeEmpty())
+		})
+
+		It("should seed inventory with listed resources", func() {
+			doReconcile(args)
+			unused := testcr.ResourceBuilder.CreateDeployment("unused-deployment", testcr.Namespace, "match-key", "match-value", "", int32(1), corev1.PodSpec{})
+			unused.Labels[createVersionLabel] = "v0.0.1"
+			Expect(controllerutil.SetControllerReference(args.config, unused, scheme.Scheme)).To(Succeed())
+			Expect(args.client.Create(context.TODO(), unused)).To(Succeed())
+
+			crManager.upgraded = false
+			args.reconciler.WithInventory()
+			doReconcile(args)
+
+			Expect(args.config.Status.Inventory).To(ContainElement(
+				sdkapi.ManagedResource{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: testcr.Namespace, Name: "unused-deployment", OperatorVersion: "v0.0.1"},
+			))
+		})
+
+		It("should not record opted out resources", func() {
+			args.reconciler.WithOptOutAnnotation("opt-out")
+			doReconcile(args)
+			deployment, err := getDeployment(args.client, operatorDeployment())
+			Expect(err).ToNot(HaveOccurred())
+			deployment.Annotations = map[string]string{"opt-out": "true"}
+			Expect(args.client.Update(context.TODO(), deployment)).To(Succeed())
+
+			args.reconciler.WithInventory()
+			doReconcile(args)
+
+			Expect(inventoryNames()).To(Equal([]string{"ConfigMap/upgrade-only"}))
+		})
+
+		It("should not seed initialized empty inventory", func() {
+			args.reconciler.WithInventory()
+			doReconcile(args)
+			Expect(args.config.Status.InventoryInitialized).To(BeTrue())
+			args.config.Status.Inventory = nil
+			Expect(args.client.Status().Update(context.TODO(), args.config)).To(Succeed())
+			unused := testcr.ResourceBuilder.CreateDeployment("unused-deployment", testcr.Namespace, "match-key", "match-value", "", int32(1), corev1.PodSpec{})
+			unused.Labels[createVersionLabel] = version
+			Expect(controllerutil.SetControllerReference(args.config, unused, scheme.Scheme)).To(Succeed())
+			Expect(args.client.Create(context.TODO(), unused)).To(Succeed())
+
+			doReconcile(args)
+
+			Expect(inventoryNames()).ToNot(ContainElement("Deployment/unused-deployment"))
+			Expect(inventoryNames()).To(ConsistOf("Deployment/"+testcr.OperatorDeploymentName, "ConfigMap/upgrade-only"))
+		})
+	})
+
+	Describe("Config CR deletion during upgrade", func() {
+		It("should delete CR if it is marked for deletion and not begin upgrade flow", func() {
+			newVersion := "v0.0.2"
+			prevVersion := "v0.0.1"
+
+			args := createArgs(newVersion)
+			doReconcile(args)
+
+			//set deployment to ready
+			isReady := setDeploymentsReady(args)
+			Expect(isReady).Should(Equal(true))
+
+			//verify on int version is set
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeployed))
+
+			//Modify CRD to be of previousVersion
+			_ = args.reconciler.CrSetVersion(args.config, prevVersion)
+			//mark CR for deletion
+			args.config.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+			err := args.client.Update(context.TODO(), args.config)
+			Expect(err).ToNot(HaveOccurred())
+
+			doReconcile(args)
+
+			//verify the version cr is deleted and upgrade hasn't started
+			Expect(args.config.Status.OperatorVersion).Should(Equal(prevVersion))
+			Expect(args.config.Status.ObservedVersion).Should(Equal(prevVersion))
+			Expect(args.config.Status.TargetVersion).Should(Equal(prevVersion))
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeleted))
+		})
+
+		It("should delete CR if it is marked for deletion during upgrade flow", func() {
+			newVersion := "v0.0.2"
+			prevVersion := "v0.0.1"
+
+			args := createArgs(newVersion)
+			doReconcile(args)
+			setDeploymentsReady(args)
+
+			//verify on int version is set
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeployed))
+
+			//Modify CRD to be of previousVersion
+			_ = args.reconciler.CrSetVersion(args.config, prevVersion)
+			err := args.client.Update(context.TODO(), args.config)
+			Expect(err).ToNot(HaveOccurred())
+			setDeploymentsDegraded(args)
+
+			//begin upgrade
+			doReconcile(args)
+
+			//mark CR for deletion
+			args.config.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+			err = args.client.Update(context.TODO(), args.config)
+			Expect(err).ToNot(HaveOccurred())
+
+			doReconcile(args)
+
+			//set deployment to ready
+			isReady := setDeploymentsReady(args)
+			Expect(isReady).Should(Equal(false))
+
+			doReconcile(args)
+			//verify the version cr is marked as deleted
+			Expect(args.config.Status.Phase).Should(Equal(sdkapi.PhaseDeleted))
+		})
+	})
+})
+
+const rolloutWaveAnnotation = "rollout-wave"
+
+// wavesCrManager deploys a ConfigMap in a wave following the test resources and, with firstWaveConfigMap set, another
+// ConfigMap in the wave of the test resources
+type wavesCrManager struct {
+	testcr.ConfigCrManager
+	wave               string
+	firstWaveConfigMap bool
+}
+
+func (m *wavesCrManager) GetAllResources(cr runtime.Object) ([]runtime.Object, error) {
+	resources, err := m.ConfigCrManager.GetAllResources(cr)
+	if err != nil {
+		return nil, err
+	}
+	configMap := wavesConfigMap()
+	configMap.Annotations = map[string]string{rolloutWaveAnnotation: "1"}
+	if m.wave != "" {
+		configMap.Annotations[rolloutWaveAnnotation] = m.wave
+	}
+	if m.firstWaveConfigMap {
+		resources = append(resources, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "first-wave",
+				Namespace: testcr.Namespace,
+			},
+		})
+	}
+	// resources of the later waves can be listed first
+	return append([]runtime.Object{configMap}, resources...), nil
+}
+
+func wavesConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "second-wave",
+			Namespace: testcr.Namespace,
+		},
+	}
+}
+
+// manyCrManager manages given number of ConfigMaps in addition to the test resources
+type manyCrManager struct {
+	testcr.ConfigCrManager
+	count int
+}
+
+func (m *manyCrManager) GetAllResources(cr runtime.Object) ([]runtime.Object, error) {
+	resources, err := m.ConfigCrManager.GetAllResources(cr)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < m.count; i++ {
+		resources = append(resources, manyConfigMap(i))
+	}
+	return resources, nil
+}
+
+func manyConfigMap(i int) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("config-map-%02d", i),
+			Namespace: testcr.Namespace,
+		},
+	}
+}
+
+// concurrencyClient records the maximal number of concurrent creates and fails creates of the objects with given names
+type concurrencyClient struct {
+	realClient.Client
+	failNames   map[string]bool
+	mutex       sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *concurrencyClient) Create(ctx context.Context, obj runtime.Object, opts ...realClient.CreateOption) error {
+	c.mutex.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		c.inFlight--
+		c.mutex.Unlock()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	name := obj.(metav1.Object).GetName()
+	if c.failNames[name] {
+		return errors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, name, fmt.Errorf("denied"))
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// clusterScopedCrManager manages a ClusterRole in addition to the test resources
+type clusterScopedCrManager struct {
+	testcr.ConfigCrManager
+}
+
+func (m *clusterScopedCrManager) GetAllResources(cr runtime.Object) ([]runtime.Object, error) {
+	resources, err := m.ConfigCrManager.GetAllResources(cr)
+	if err != nil {
+		return nil, err
+	}
+	return append(resources, clusterRole("cluster-scoped-role")), nil
+}
+
+func (m *clusterScopedCrManager) GetDependantResourcesListObjects() []runtime.Object {
+	return append(m.ConfigCrManager.GetDependantResourcesListObjects(), &rbacv1.ClusterRoleList{})
+}
+
+func clusterRole(name string) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+// readsClient counts the reads per object type; with missing set, all reads of the managed resources are not found
+type readsClient struct {
+	realClient.Client
+	gets    map[string]int
+	lists   map[string]int
+	missing bool
+}
+
+func (c *readsClient) List(ctx context.Context, list runtime.Object, opts ...realClient.ListOption) error {
+	c.lists[fmt.Sprintf("%T", list)]++
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *readsClient) Get(ctx context.Context, key realClient.ObjectKey, obj runtime.Object) error {
+	if _, ok := obj.(*testcr.Config); ok {
+		return c.Client.Get(ctx, key, obj)
+	}
+	c.gets[fmt.Sprintf("%T", obj)]++
+	if c.missing {
+		return errors.NewNotFound(schema.GroupResource{}, key.Name)
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+// unregisteredConfigMap is a kind unknown to the scheme
+type unregisteredConfigMap struct {
+	corev1.ConfigMap
+}
+
+// droppingClient pretends to create the objects of given name, like when they are removed right after the creation
+type droppingClient struct {
+	realClient.Client
+	name string
+}
+
+func (c *droppingClient) Create(ctx context.Context, obj runtime.Object, opts ...realClient.CreateOption) error {
+	if obj.(metav1.Object).GetName() == c.name {
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// failingClient fails given number of the Deployment updates with conflict and the remaining ones with given error
+type failingClient struct {
+	realClient.Client
+	conflicts int
+	err       error
+}
+
+func (c *failingClient) Update(ctx context.Context, obj runtime.Object, opts ...realClient.UpdateOption) error {
+	if deployment, ok := obj.(*appsv1.Deployment); ok {
+		if c.conflicts > 0 {
+			c.conflicts--
+			return errors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, deployment.Name, fmt.Errorf("modified"))
+		}
+		if c.err != nil {
+			return c.err
+		}
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// statusClient counts the updates and the status patches of the CR and runs given function before the first status
+// patch, i.e. to make it conflict; with statusPatchErr set, the status patches fail
+type statusClient struct {
+	realClient.Client
+	crUpdates         int
+	statusPatches     int
+	statusPatchErr    error
+	beforeStatusPatch func()
+}
+
+type statusClientWriter struct {
+	realClient.StatusWriter
+	c *statusClient
+}
+
+func (c *statusClient) Update(ctx context.Context, obj runtime.Object, opts ...realClient.UpdateOption) error {
+	if _, ok := obj.(*testcr.Config); ok {
+		c.crUpdates++
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *statusClient) Status() realClient.StatusWriter {
+	return &statusClientWriter{StatusWriter: c.Client.Status(), c: c}
+}
+
+func (w *statusClientWriter) Patch(ctx context.Context, obj runtime.Object, patch realClient.Patch, opts ...realClient.PatchOption) error {
+	w.c.statusPatches++
+	if w.c.beforeStatusPatch != nil {
+		beforeStatusPatch := w.c.beforeStatusPatch
+		w.c.beforeStatusPatch = nil
+		beforeStatusPatch()
+	}
+	if w.c.statusPatchErr != nil {
+		return w.c.statusPatchErr
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// rollbackCrManager changes the test deployment and adds a ConfigMap when upgraded
+type rollbackCrManager struct {
+	testcr.ConfigCrManager
+	upgraded bool
+}
+
+func (m *rollbackCrManager) GetAllResources(cr runtime.Object) ([]runtime.Object, error) {
+	resources, err := m.ConfigCrManager.GetAllResources(cr)
+	if err != nil || !m.upgraded {
+		return resources, err
+	}
+	for _, resource := range resources {
+		if deployment, ok := resource.(*appsv1.Deployment); ok {
+			deployment.Spec.Template.Spec.Containers[0].Env[0].Value = "UPGRADED"
+		}
+	}
+	return append(resources, upgradeConfigMap()), nil
+}
+
+func operatorDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: testcr.OperatorDeploymentName, Namespace: testcr.Namespace}}
+}
+
+func upgradeConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "upgrade-only",
+			Namespace: testcr.Namespace,
+		},
+	}
+}
+
+// unstructuredCrManager manages unstructured resources in addition to the typed test resources
+type unstructuredCrManager struct {
+	testcr.ConfigCrManager
+	withService bool
+}
+
+func (m *unstructuredCrManager) GetAllResources(cr runtime.Object) ([]runtime.Object, error) {
+	resources, err := m.ConfigCrManager.GetAllResources(cr)
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, unstructuredConfigMap())
+	if m.withService {
+		resources = append(resources, unstructuredService())
+	}
+	return resources, nil
+}
+
+func (m *unstructuredCrManager) GetDependantResourcesListObjects() []runtime.Object {
+	// typed lists are matched with the unstructured desired resources by kind
+	return append(m.ConfigCrManager.GetDependantResourcesListObjects(), &corev1.ConfigMapList{}, &corev1.ServiceList{})
+}
+
+func unstructuredConfigMap() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      "unstructured",
+			"namespace": testcr.Namespace,
+		},
+		"data": map[string]interface{}{
+			"key": "value",
+		},
+	}}
+}
+
+func unstructuredService() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":      "unstructured",
+			"namespace": testcr.Namespace,
+		},
+		"spec": map[string]interface{}{
+			"ports": []interface{}{
+				map[string]interface{}{"port": int64(80)},
+			},
+		},
+	}}
+}
+
+// typedStorageClient stores unstructured objects as typed ones, because the fake client can't list them otherwise
+type typedStorageClient struct {
+	realClient.Client
+}
+
+func (c *typedStorageClient) Create(ctx context.Context, obj runtime.Object, opts ...realClient.CreateOption) error {
+	typed, err := toTyped(obj)
+	if err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, typed, opts...)
+}
+
+func (c *typedStorageClient) Update(ctx context.Context, obj runtime.Object, opts ...realClient.UpdateOption) error {
+	typed, err := toTyped(obj)
+	if err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, typed, opts...)
+}
+
+func toTyped(obj runtime.Object) (runtime.Object, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+
+	typed, err := scheme.Scheme.New(u.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
+
+// applyClient emulates server-side apply on top of the fake client, which does not support it
+type applyClient struct {
+	realClient.Client
+	fieldManagers []string
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj runtime.Object, patch realClient.Patch, opts ...realClient.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	patchOptions := &realClient.PatchOptions{}
+	patchOptions.ApplyOptions(opts)
+	if len(patchOptions.DryRun) > 0 {
+		return nil
+	}
+	c.fieldManagers = append(c.fieldManagers, patchOptions.FieldManager)
+
+	current, err := getObject(c.Client, obj)
+	if errors.IsNotFound(err) {
+		return c.Client.Create(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+	obj.(metav1.Object).SetResourceVersion(current.(metav1.Object).GetResourceVersion())
+	return c.Client.Update(ctx, obj)
+}
+
+func getConfig(c realClient.Client, cr *testcr.Config) (*testcr.Config, error) {
+	result, err := getObject(c, cr)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*testcr.Config), nil
+}
+
+func createClient(scheme *runtime.Scheme, objs ...runtime.Object) realClient.Client {
+	return fakeClient.NewFakeClientWithScheme(scheme, objs...)
+}
+
+func createReconciler(client realClient.Client, s *runtime.Scheme) *reconciler.Reconciler {
+	crManager := &testcr.ConfigCrManager{}
+	return reconciler.NewReconciler(crManager, log, client, callbackDispatcher, s, createVersionLabel, "update-version", "last-applied-config", 0, finalizerName)
+}
+
+func createConfig(name, uid string) *testcr.Config {
+	return &testcr.Config{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(uid)}, Status: testcr.ConfigStatus{}}
+}
+
+func (m *mockCallbackDispatcher) InvokeCallbacks(_ logr.Logger, cr interface{}, s callbacks.ReconcileState, desiredObj, currentObj runtime.Object) error {
+	return invokeCallbacks(cr, s, desiredObj, currentObj)
+}
+
+func (m *mockCallbackDispatcher) AddCallback(obj runtime.Object, cb callbacks.ReconcileCallback) {
+	addCallback(obj, cb)
+}
+
+func reconcileRequest(config *testcr.Config) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: config.Namespace, Name: config.Name}}
+}
+
+func createArgs(version string) *args {
+	config := createConfig("test", "unique-id")
+	s := scheme.Scheme
+	err := testcr.AddToScheme(s)
+	if err != nil {
+		Fail(err.Error())
+	}
+	err = extv1.AddToScheme(s)
+	if err != nil {
+		Fail(err.Error())
+	}
+	client := createClient(s, config)
+	mockController := mocks.MockController{}
+	r := createReconciler(client, s)
+	r.WithController(&mockController)
+
+	return &args{
+		config:         config,
+		client:         client,
+		reconciler:     r,
+		version:        version,
+		mockController: &mockController,
+	}
+}
+
+func doReconcile(args *args) {
+	result, err := args.reconciler.Reconcile(reconcileRequest(args.config), args.version, log)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(result.Requeue).To(BeFalse())
+
+	args.config, err = getConfig(args.client, args.config)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+func doReconcileError(args *args) {
+	result, err := args.reconciler.Reconcile(reconcileRequest(args.config), args.version, log)
+	Expect(err).To(HaveOccurred())
+	Expect(result.Requeue).To(BeFalse())
+
+	args.config, err = getConfig(args.client, args.config)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+func setDeploymentsReady(args *args) bool {
+	crManager := testcr.ConfigCrManager{}
+	resources, err := crManager.GetAllResources(args.config)
+	Expect(err).ToNot(HaveOccurred())
+	running := false
+
+	for _, r := range resources {
+		d, ok := r.(*appsv1.Deployment)
+		if !ok {
+			continue
+		}
+
+		Expect(running).To(BeFalse())
+
+		d, err := getDeployment(args.client, d)
+		Expect(err).ToNot(HaveOccurred())
+		if d.Spec.Replicas != nil {
+			d.Status.Replicas = *d.Spec.Replicas
+			d.Status.ReadyReplicas = d.Status.Replicas
+			err = args.client.Update(context.TODO(), d)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		doReconcile(args)
+
+		if len(args.config.Status.Conditions) == 3 &&
+			v1.IsStatusConditionTrue(args.config.Status.Conditions, v1.ConditionAvailable) &&
+			v1.IsStatusConditionFalse(args.config.Status.Conditions, v1.ConditionProgressing) &&
+			v1.IsStatusConditionFalse(args.config.Status.Conditions, v1.ConditionDegraded) {
+			running = true
+		}
+	}
 
 	return running
 }
@@ -783,8 +2590,7 @@ func getObject(client realClient.Client, obj runtime.Object) (runtime.Object, er
 	metaObj := obj.(metav1.Object)
 	key := realClient.ObjectKey{Namespace: metaObj.GetNamespace(), Name: metaObj.GetName()}
 
-	typ := reflect.ValueOf(obj).Elem().Type()
-	result := reflect.New(typ).Interface().(runtime.Object)
+	result := sdk.NewDefaultInstance(obj)
 
 	if err := client.Get(context.TODO(), key, result); err != nil {
 		return nil, err
@@ -809,3 +2615,49 @@ func getModifiedResource(args *args, modify modifyResource, tomodify isModifySub
 	//apply modify function on resource and return modified one
 	return modify(orig)
 }
+
+// recordedEvents drains the events recorded so far
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// metricValue returns the value of the gauge or counter with given name and labels from the controller-runtime registry
+func metricValue(name string, labels map[string]string) float64 {
+	families, err := metrics.Registry.Gather()
+	Expect(err).ToNot(HaveOccurred())
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metric:
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value != label.GetValue() {
+					continue metric
+				}
+			}
+			if m.GetCounter() != nil {
+				return m.GetCounter().GetValue()
+			}
+			return m.GetGauge().GetValue()
+		}
+	}
+	return 0
+}
+
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	result := map[string]string{name: value}
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
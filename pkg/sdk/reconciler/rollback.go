@@ -0,0 +1,202 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
+	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// UpgradeTimedOutReason is the reason of the condition reporting an upgrade not completed within the upgrade timeout
+const UpgradeTimedOutReason = "UpgradeTimedOut"
+
+// upgradeTimedOut checks whether the upgrade in progress exceeded the upgrade timeout
+func (r *Reconciler) upgradeTimedOut(status *sdkapi.Status) bool {
+	if r.upgradeTimeout <= 0 || status.Phase != sdkapi.PhaseUpgrading || status.UpgradeInProgress == nil {
+		return false
+	}
+	return time.Since(status.UpgradeInProgress.StartTime.Time) > r.upgradeTimeout
+}
+
+// upgradeFailed checks whether the upgrade to the operator version has already failed, in which case the CR is not
+// reconciled until the operator version changes
+func upgradeFailed(status *sdkapi.Status, operatorVersion string) bool {
+	if status.Phase != sdkapi.PhaseError || len(status.UpgradeHistory) == 0 || status.ObservedVersion == operatorVersion {
+		return false
+	}
+	last := status.UpgradeHistory[0]
+	return last.Result == sdkapi.UpgradeFailed && last.ToVersion == operatorVersion
+}
+
+// upgradeRequeueAfter shortens the requeue interval, so that the reconciliation is executed when the upgrade in
+// progress times out
+func (r *Reconciler) upgradeRequeueAfter(status *sdkapi.Status, interval time.Duration) time.Duration {
+	if r.upgradeTimeout <= 0 || status.Phase != sdkapi.PhaseUpgrading || status.UpgradeInProgress == nil {
+		return interval
+	}
+	remaining := r.upgradeTimeout - time.Since(status.UpgradeInProgress.StartTime.Time)
+	if remaining < time.Second {
+		remaining = time.Second
+	}
+	if interval <= 0 || remaining < interval {
+		return remaining
+	}
+	return interval
+}
+
+// failUpgrade marks the timed out upgrade failed and rolls the managed resources back to the previous version when
+// enabled
+func (r *Reconciler) failUpgrade(logger logr.Logger, cr controllerutil.Object) error {
+	status := r.status(cr)
+	fromVersion := status.UpgradeInProgress.FromVersion
+	message := fmt.Sprintf("Upgrade from version %s to %s did not complete within %s", fromVersion, status.TargetVersion, r.upgradeTimeout)
+	logger.Info("Upgrade timed out", "from version", fromVersion, "to version", status.TargetVersion)
+
+	if r.rollbackAnnotation != "" {
+		if err := r.rollbackResources(logger, cr); err != nil {
+			logger.Error(err, "Unable to roll back the managed resources")
+			message = fmt.Sprintf("%s, rollback to version %s failed: %v", message, fromVersion, err)
+		} else {
+			message = fmt.Sprintf("%s, rolled back to version %s", message, fromVersion)
+		}
+	}
+
+	sdk.MarkCrFailed(status, UpgradeTimedOutReason, message)
+	r.finishUpgradeProgress(status, sdkapi.UpgradeFailed)
+	if err := r.CrUpdate(sdkapi.PhaseError, cr); err != nil {
+		return err
+	}
+	r.recordEvent(cr, corev1.EventTypeWarning, UpgradeFailedReason, "%s", message)
+	return nil
+}
+
+// setRollbackConfiguration stores the last applied configuration of the resource in the cluster in the rollback
+// annotation of the merged resource, when the resource is updated for the first time during the upgrade
+func (r *Reconciler) setRollbackConfiguration(status *sdkapi.Status, clusterMetaObj, currentMetaObj metav1.Object, operatorVersion string) {
+	if r.rollbackAnnotation == "" || status.Phase != sdkapi.PhaseUpgrading || r.writtenVersion(clusterMetaObj) == operatorVersion {
+		return
+	}
+	lastApplied, ok := clusterMetaObj.GetAnnotations()[r.lastAppliedConfigAnnotation]
+	if !ok {
+		return
+	}
+	annotations := currentMetaObj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[r.rollbackAnnotation] = lastApplied
+	currentMetaObj.SetAnnotations(annotations)
+}
+
+// writtenVersion returns the operator version, which last created or updated the resource
+func (r *Reconciler) writtenVersion(metaObj metav1.Object) string {
+	labels := metaObj.GetLabels()
+	if version, ok := labels[r.updateVersionLabel]; ok {
+		return version
+	}
+	return labels[r.createVersionLabel]
+}
+
+// rollbackResources re-applies the configuration of the previous version stored in the rollback annotation of the
+// managed resources updated during the upgrade and removes the resources created during the upgrade
+func (r *Reconciler) rollbackResources(logger logr.Logger, cr controllerutil.Object) error {
+	status := r.status(cr)
+	resources, err := r.crManager.GetAllResources(cr)
+	if err != nil {
+		return err
+	}
+
+	for _, desiredRuntimeObj := range resources {
+		currentRuntimeObj := sdk.NewDefaultInstance(desiredRuntimeObj)
+		key, err := client.ObjectKeyFromObject(desiredRuntimeObj)
+		if err != nil {
+			return err
+		}
+		if err = r.getResource(key, currentRuntimeObj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if err = r.rollbackResource(logger, cr, currentRuntimeObj, status.TargetVersion, status.ObservedVersion); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler) rollbackResource(logger logr.Logger, cr controllerutil.Object, currentRuntimeObj runtime.Object, targetVersion, previousVersion string) error {
+	currentMetaObj := currentRuntimeObj.(metav1.Object)
+	if r.writtenVersion(currentMetaObj) != targetVersion || r.isOptedOut(currentRuntimeObj) {
+		return nil
+	}
+
+	if currentMetaObj.GetLabels()[r.createVersionLabel] == targetVersion {
+		if err := r.client.Delete(context.TODO(), currentRuntimeObj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("Resource created during upgrade deleted",
+			"namespace", currentMetaObj.GetNamespace(),
+			"name", currentMetaObj.GetName(),
+			"type", fmt.Sprintf("%T", currentMetaObj))
+		r.recordResourceEvent(cr, ResourceDeletedReason, "Deleted", currentRuntimeObj)
+		r.countResourceOperation(currentRuntimeObj, resourceDeleted)
+		return nil
+	}
+
+	rollbackConfig, ok := currentMetaObj.GetAnnotations()[r.rollbackAnnotation]
+	if !ok {
+		return nil
+	}
+	previousRuntimeObj := sdk.NewDefaultInstance(currentRuntimeObj)
+	if err := json.Unmarshal([]byte(rollbackConfig), previousRuntimeObj); err != nil {
+		return err
+	}
+	previousMetaObj := previousRuntimeObj.(metav1.Object)
+
+	currentRuntimeObj, err := sdk.StripStatusFromObject(currentRuntimeObj)
+	if err != nil {
+		return err
+	}
+	currentMetaObj = currentRuntimeObj.(metav1.Object)
+	sdk.MergeLabelsAndAnnotations(previousMetaObj, currentMetaObj)
+	r.setLastAppliedConfiguration(previousMetaObj)
+
+	gvk, err := apiutil.GVKForObject(currentRuntimeObj, r.scheme)
+	if err != nil {
+		return err
+	}
+	currentRuntimeObj, err = r.mergeStrategies.StrategyFor(gvk)(previousRuntimeObj, currentRuntimeObj, r.lastAppliedConfigAnnotation)
+	if err != nil {
+		return err
+	}
+	currentMetaObj = currentRuntimeObj.(metav1.Object)
+
+	annotations := currentMetaObj.GetAnnotations()
+	delete(annotations, r.rollbackAnnotation)
+	currentMetaObj.SetAnnotations(annotations)
+	sdk.SetLabel(r.updateVersionLabel, previousVersion, currentMetaObj)
+
+	if err = r.client.Update(context.TODO(), currentRuntimeObj); err != nil {
+		return err
+	}
+	logger.Info("Resource rolled back",
+		"namespace", currentMetaObj.GetNamespace(),
+		"name", currentMetaObj.GetName(),
+		"type", fmt.Sprintf("%T", currentMetaObj))
+	r.recordResourceEvent(cr, ResourceUpdatedReason, "Rolled back", currentRuntimeObj)
+	r.countResourceOperation(currentRuntimeObj, resourceUpdated)
+	return nil
+}
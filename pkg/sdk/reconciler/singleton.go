@@ -0,0 +1,103 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk"
+	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// SingletonRejectedReason is the reason of the condition reporting a CR rejected by the singleton policy
+const SingletonRejectedReason = "SingletonRejected"
+
+// singletonRequeueInterval is the interval of checking whether the rejected CR became the oldest one
+const singletonRequeueInterval = time.Minute
+
+// singletonRejection returns the message explaining why the CR is rejected by the singleton policy, or an empty string
+// when the CR is allowed
+func (r *Reconciler) singletonRejection(cr controllerutil.Object) (string, error) {
+	if r.singletonName != "" {
+		if cr.GetName() != r.singletonName {
+			return fmt.Sprintf("Only the CR named %s is reconciled", r.singletonName), nil
+		}
+		return "", nil
+	}
+
+	// the accepted CR stays the oldest one, so the CRs are listed only until the CR is accepted
+	if singletonAccepted(r.status(cr)) {
+		return "", nil
+	}
+	first, err := r.oldestCr()
+	if err != nil {
+		return "", err
+	}
+	if first == nil || (first.GetNamespace() == cr.GetNamespace() && first.GetName() == cr.GetName()) {
+		return "", nil
+	}
+	return fmt.Sprintf("Only one CR is reconciled, %s already exists", ownerReference(first)), nil
+}
+
+// singletonAccepted checks whether the CR has been accepted by the singleton policy, i.e. its creation has started and
+// it has not been rejected since
+func singletonAccepted(status *sdkapi.Status) bool {
+	return status.Phase != sdkapi.PhaseEmpty && !failedWithReason(status, SingletonRejectedReason, OrphanResourcesReason, ForeignOwnedResourcesReason)
+}
+
+// oldestCr returns the CR created first; CRs created at the same time are ordered by their namespace and name
+func (r *Reconciler) oldestCr() (metav1.Object, error) {
+	gvk, err := apiutil.GVKForObject(r.crManager.Create(), r.scheme)
+	if err != nil {
+		return nil, err
+	}
+	list, err := r.scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err != nil {
+		return nil, err
+	}
+	if err = r.client.List(context.TODO(), list); err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	var first metav1.Object
+	for _, item := range items {
+		metaObj := item.(metav1.Object)
+		if first == nil || createdBefore(metaObj, first) {
+			first = metaObj
+		}
+	}
+	return first, nil
+}
+
+func createdBefore(a, b metav1.Object) bool {
+	aTime, bTime := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !aTime.Equal(&bTime) {
+		return aTime.Before(&bTime)
+	}
+	return ownerReference(a) < ownerReference(b)
+}
+
+// rejectCr moves the CR rejected by the singleton policy to the Error phase without touching the managed resources
+func (r *Reconciler) rejectCr(logger logr.Logger, cr controllerutil.Object, message string) (reconcile.Result, error) {
+	logger.Info("CR rejected by singleton policy", "reason", message)
+	status := r.status(cr)
+	sdk.MarkCrFailed(status, SingletonRejectedReason, message)
+	if err := r.CrUpdate(sdkapi.PhaseError, cr); err != nil {
+		return reconcile.Result{}, err
+	}
+	if r.singletonName != "" {
+		return reconcile.Result{}, nil
+	}
+	// the CR takes over once the older CRs are removed
+	return reconcile.Result{RequeueAfter: singletonRequeueInterval}, nil
+}
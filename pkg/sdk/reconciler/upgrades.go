@@ -0,0 +1,142 @@
+package reconciler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+	sdkapi "github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// UpgradePathRefusedReason is the reason of the condition and event reporting an upgrade not allowed by the upgrade graph
+const UpgradePathRefusedReason = "UpgradePathRefused"
+
+// UpgradeGraph constrains the operator upgrades. Without any edges or mandatory versions all upgrades are allowed.
+// Versions that do not adhere to the semver spec are not constrained
+type UpgradeGraph struct {
+	edges     []upgradeEdge
+	mandatory []semver.Version
+}
+
+type upgradeEdge struct {
+	from, to semver.Range
+}
+
+// NewUpgradeGraph creates new UpgradeGraph allowing all upgrades
+func NewUpgradeGraph() *UpgradeGraph {
+	return &UpgradeGraph{}
+}
+
+// AllowUpgrade allows upgrades from the versions in the from range to the versions in the to range, i.e. "1.4.x" to
+// "1.5.x". Once an upgrade is allowed, only the allowed upgrades can be performed
+func (g *UpgradeGraph) AllowUpgrade(from, to string) error {
+	fromRange, err := semver.ParseRange(from)
+	if err != nil {
+		return err
+	}
+	toRange, err := semver.ParseRange(to)
+	if err != nil {
+		return err
+	}
+	g.edges = append(g.edges, upgradeEdge{from: fromRange, to: toRange})
+	return nil
+}
+
+// RequireVersion makes the version mandatory, so that upgrades from a lower version to a higher one have to pass through it
+func (g *UpgradeGraph) RequireVersion(version string) error {
+	v, err := semver.Make(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return err
+	}
+	g.mandatory = append(g.mandatory, v)
+	return nil
+}
+
+// CheckUpgrade returns error when the upgrade from the current version to the target one is not allowed
+func (g *UpgradeGraph) CheckUpgrade(currentVersion, targetVersion string) error {
+	if g == nil {
+		return nil
+	}
+	target, current, ok := parseVersions(targetVersion, currentVersion)
+	if !ok {
+		return nil
+	}
+
+	for _, mandatory := range g.mandatory {
+		if current.LT(mandatory) && target.GT(mandatory) {
+			return fmt.Errorf("upgrade from version %s to %s skips mandatory version %s", currentVersion, targetVersion, mandatory)
+		}
+	}
+
+	if len(g.edges) == 0 {
+		return nil
+	}
+	for _, edge := range g.edges {
+		if edge.from(current) && edge.to(target) {
+			return nil
+		}
+	}
+	return fmt.Errorf("upgrade from version %s to %s is not allowed by the upgrade graph", currentVersion, targetVersion)
+}
+
+// defaultUpgradeHistoryLimit is the default number of the finished upgrades kept in the CR status
+const defaultUpgradeHistoryLimit = 10
+
+// startUpgradeProgress records the started upgrade in the CR status
+func startUpgradeProgress(status *sdkapi.Status, fromVersion, toVersion string) {
+	status.UpgradeInProgress = &sdkapi.UpgradeProgress{
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		StartTime:   metav1.Now(),
+	}
+}
+
+// setUpgradeProgress records the number of the managed resources upgraded to the target version during upgrade, as
+// reported by the current pass, out of the total number of the managed resources, except for the opted out ones. The
+// resources of the rollout waves not reached yet are counted as not upgraded
+func (r *Reconciler) setUpgradeProgress(cr runtime.Object, upgraded, total int) error {
+	status := r.status(cr)
+	if status.Phase != sdkapi.PhaseUpgrading {
+		return nil
+	}
+	started := false
+	if status.UpgradeInProgress == nil {
+		// the upgrade started before the progress was recorded
+		startUpgradeProgress(status, status.ObservedVersion, status.TargetVersion)
+		started = true
+	}
+
+	progress := *status.UpgradeInProgress
+	progress.ToVersion = status.TargetVersion
+	progress.UpgradedResources = int32(upgraded)
+	progress.TotalResources = int32(total)
+	if !started && progress == *status.UpgradeInProgress {
+		return nil
+	}
+
+	status.UpgradeInProgress = &progress
+	return r.CrUpdate(status.Phase, cr)
+}
+
+// finishUpgradeProgress moves the upgrade in progress to the bounded upgrade history in the CR status
+func (r *Reconciler) finishUpgradeProgress(status *sdkapi.Status, result sdkapi.UpgradeResult) {
+	progress := status.UpgradeInProgress
+	if progress == nil {
+		return
+	}
+
+	record := sdkapi.UpgradeRecord{
+		FromVersion: progress.FromVersion,
+		ToVersion:   progress.ToVersion,
+		StartTime:   progress.StartTime,
+		FinishTime:  metav1.Now(),
+		Result:      result,
+	}
+	status.UpgradeHistory = append([]sdkapi.UpgradeRecord{record}, status.UpgradeHistory...)
+	if len(status.UpgradeHistory) > r.upgradeHistoryLimit {
+		status.UpgradeHistory = status.UpgradeHistory[:r.upgradeHistoryLimit]
+	}
+	status.UpgradeInProgress = nil
+}
@@ -0,0 +1,50 @@
+package reconciler_test
+
+import (
+	"github.com/jakub-dzon/controller-lifecycle-operator-sdk/pkg/sdk/reconciler"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upgrade graph", func() {
+	It("should allow all upgrades when empty", func() {
+		Expect(reconciler.NewUpgradeGraph().CheckUpgrade("1.0.0", "5.0.0")).To(Succeed())
+	})
+
+	It("should allow all upgrades when not set", func() {
+		var graph *reconciler.UpgradeGraph
+		Expect(graph.CheckUpgrade("1.0.0", "5.0.0")).To(Succeed())
+	})
+
+	DescribeTable("should check upgrade", func(currentVersion, targetVersion string, allowed bool) {
+		graph := reconciler.NewUpgradeGraph()
+		Expect(graph.AllowUpgrade("1.4.x", "1.5.x")).To(Succeed())
+		Expect(graph.AllowUpgrade(">=1.5.0 <1.6.0", ">=1.5.0 <=1.7.0")).To(Succeed())
+		Expect(graph.RequireVersion("v1.6.0")).To(Succeed())
+
+		err := graph.CheckUpgrade(currentVersion, targetVersion)
+
+		if allowed {
+			Expect(err).ToNot(HaveOccurred())
+		} else {
+			Expect(err).To(HaveOccurred())
+		}
+	},
+		Entry("along an edge", "1.4.2", "1.5.0", true),
+		Entry("along an edge with v-prefixed versions", "v1.4.2", "v1.5.3", true),
+		Entry("within a range", "1.5.0", "1.5.1", true),
+		Entry("to mandatory version", "1.5.1", "1.6.0", true),
+		Entry("skipping a minor version", "1.4.2", "1.6.0", false),
+		Entry("skipping mandatory version", "1.5.1", "1.7.0", false),
+		Entry("not declared", "1.3.0", "1.4.0", false),
+		Entry("from invalid semver", "devel", "1.9.0", true),
+	)
+
+	It("should reject invalid constraints", func() {
+		graph := reconciler.NewUpgradeGraph()
+
+		Expect(graph.AllowUpgrade("latest", "1.5.x")).ToNot(Succeed())
+		Expect(graph.RequireVersion("next")).ToNot(Succeed())
+	})
+})
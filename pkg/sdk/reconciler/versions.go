@@ -9,41 +9,51 @@ import (
 
 // ShouldTakeUpdatePath checks whether upgrade-type reconciliation should be executed. Returns error in case of downgrade
 func ShouldTakeUpdatePath(targetVersion, currentVersion string, deploying bool) (bool, error) {
+	if !deploying && IsDowngrade(targetVersion, currentVersion) {
+		return false, fmt.Errorf("operator downgraded, will not reconcile")
+	}
+	return shouldTakeUpdatePath(targetVersion, currentVersion, deploying), nil
+}
+
+// IsDowngrade checks whether the target version is lower than the current one. Versions that do not adhere to the semver
+// spec are never considered a downgrade
+func IsDowngrade(targetVersion, currentVersion string) bool {
+	target, current, ok := parseVersions(targetVersion, currentVersion)
+	return ok && target.Compare(current) < 0
+}
 
+// shouldTakeUpdatePath checks whether upgrade-type reconciliation should be executed, treating downgrade as an update
+func shouldTakeUpdatePath(targetVersion, currentVersion string, deploying bool) bool {
 	if deploying {
-		return false, nil
+		return false
 	}
 	if targetVersion == currentVersion {
-		return false, nil
+		return false
 	}
 
 	// if no current version, then we can't perform semantic version comparison. But since the target version is not
 	// empty, and since we are not deploying, then we're upgrading
 	if currentVersion == "" {
-		return true, nil
+		return true
 	}
 
-	// semver doesn't like the 'v' prefix
-	targetVersion = strings.TrimPrefix(targetVersion, "v")
-	currentVersion = strings.TrimPrefix(currentVersion, "v")
-
 	// our default position is that this is an update.
 	// So if the target and current version do not
 	// adhere to the semver spec, we assume by default the
 	// update path is the correct path.
-	shouldTakeUpdatePath := true
-	target, err := semver.Make(targetVersion)
-	if err == nil {
-		current, err := semver.Make(currentVersion)
-		if err == nil {
-			if target.Compare(current) < 0 {
-				err := fmt.Errorf("operator downgraded, will not reconcile")
-				return false, err
-			} else if target.Compare(current) == 0 {
-				shouldTakeUpdatePath = false
-			}
-		}
-	}
+	target, current, ok := parseVersions(targetVersion, currentVersion)
+	return !ok || target.Compare(current) != 0
+}
 
-	return shouldTakeUpdatePath, nil
+func parseVersions(targetVersion, currentVersion string) (semver.Version, semver.Version, bool) {
+	// semver doesn't like the 'v' prefix
+	target, err := semver.Make(strings.TrimPrefix(targetVersion, "v"))
+	if err != nil {
+		return semver.Version{}, semver.Version{}, false
+	}
+	current, err := semver.Make(strings.TrimPrefix(currentVersion, "v"))
+	if err != nil {
+		return semver.Version{}, semver.Version{}, false
+	}
+	return target, current, true
 }
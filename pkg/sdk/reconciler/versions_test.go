@@ -56,4 +56,14 @@ var _ = Describe("Version downgrade", func() {
 		Expect(err).To(HaveOccurred())
 		Expect(upgrade).To(BeFalse())
 	})
+
+	DescribeTable("should be detected", func(currentVersion, targetVersion string, expected bool) {
+		Expect(reconciler.IsDowngrade(targetVersion, currentVersion)).To(Equal(expected))
+	},
+		Entry("for lower version", "v0.0.2", "v0.0.1", true),
+		Entry("for same version", "v0.0.1", "0.0.1", false),
+		Entry("for higher version", "0.0.1", "0.0.2", false),
+		Entry("for invalid semver", "devel", "0.0.1", false),
+		Entry("for empty current version", "", "0.0.1", false),
+	)
 })
@@ -0,0 +1,81 @@
+package reconciler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// rolloutWaveRequeueInterval is the interval of checking whether the rollout can proceed to the next wave
+const rolloutWaveRequeueInterval = 5 * time.Second
+
+// rolloutWave is a group of resources that are deployed together
+type rolloutWave struct {
+	number    int32
+	resources []runtime.Object
+}
+
+// rolloutWaves groups resources by the value of the rollout wave annotation, in ascending order of the waves.
+// Resources without the annotation belong to the wave 0; the order of resources within a wave is preserved.
+func (r *Reconciler) rolloutWaves(resources []runtime.Object) ([]rolloutWave, error) {
+	if r.rolloutWaveAnnotation == "" {
+		return []rolloutWave{{resources: resources}}, nil
+	}
+
+	byNumber := make(map[int32][]runtime.Object)
+	for _, resource := range resources {
+		metaObj := resource.(metav1.Object)
+		number := int32(0)
+		if value, ok := metaObj.GetAnnotations()[r.rolloutWaveAnnotation]; ok {
+			parsed, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rollout wave %q of %s/%s: %v", value, metaObj.GetNamespace(), metaObj.GetName(), err)
+			}
+			number = int32(parsed)
+		}
+		byNumber[number] = append(byNumber[number], resource)
+	}
+
+	var waves []rolloutWave
+	for number, waveResources := range byNumber {
+		waves = append(waves, rolloutWave{number: number, resources: waveResources})
+	}
+	sort.Slice(waves, func(i, j int) bool {
+		return waves[i].number < waves[j].number
+	})
+
+	return waves, nil
+}
+
+// waveReady checks whether all the resources of the wave exist and are ready
+func (r *Reconciler) waveReady(logger logr.Logger, wave rolloutWave) (bool, error) {
+	unready, err := r.checkResourcesReadiness(wave.resources, true)
+	if err != nil {
+		return false, err
+	}
+
+	for _, resource := range unready {
+		logger.Info("Rollout wave resource is not ready", "wave", wave.number, "resource", resource.String())
+	}
+	return len(unready) == 0, nil
+}
+
+// setRolloutWave records the wave being deployed in the CR status
+func (r *Reconciler) setRolloutWave(cr runtime.Object, number int32) error {
+	if r.rolloutWaveAnnotation == "" {
+		return nil
+	}
+
+	status := r.status(cr)
+	if status.RolloutWave != nil && *status.RolloutWave == number {
+		return nil
+	}
+
+	status.RolloutWave = &number
+	return r.CrUpdate(status.Phase, cr)
+}
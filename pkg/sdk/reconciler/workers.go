@@ -0,0 +1,67 @@
+package reconciler
+
+import (
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// resourceResult is the outcome of the reconciliation of single desired resource
+type resourceResult struct {
+	// optedOut reports the existing resource left unchanged because of the opt-out annotation
+	optedOut bool
+	// version is the operator version the resource conforms to, i.e. the version it was last written by when the write
+	// failed
+	version string
+	// writeErr represents failed write to the cluster, which does not stop the reconciliation of other resources
+	writeErr error
+	err      error
+}
+
+// reconcileResources reconciles the desired resources with the configured number of concurrent workers. Each resource,
+// with its callbacks, is reconciled by single worker. The results are returned in the order of the resources; resources
+// following a resource failing with error are not reconciled
+func (r *Reconciler) reconcileResources(logger logr.Logger, cr controllerutil.Object, resources []runtime.Object, operatorVersion string) []resourceResult {
+	results := make([]resourceResult, len(resources))
+
+	var (
+		mutex   sync.Mutex
+		next    int
+		stopped bool
+		wg      sync.WaitGroup
+	)
+	// resources are taken in order, so that all resources preceding a failed one are reconciled
+	take := func() (int, bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if stopped || next == len(resources) {
+			return 0, false
+		}
+		next++
+		return next - 1, true
+	}
+
+	workers := r.workers
+	if workers > len(resources) {
+		workers = len(resources)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, ok := take(); ok; i, ok = take() {
+				results[i] = r.reconcileResourceWithRetry(logger, cr, resources[i], operatorVersion)
+				if results[i].err != nil {
+					mutex.Lock()
+					stopped = true
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
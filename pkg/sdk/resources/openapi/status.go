@@ -20,6 +20,135 @@ func OperatorConfigStatus(operatorName string) extv1.JSONSchemaProps {
 				Description: "The version of the " + operatorName + " resource as defined by the operator",
 				Type:        "string",
 			},
+			"rolloutWave": {
+				Description: "The rollout wave of the " + operatorName + " resources that is being deployed",
+				Type:        "integer",
+				Format:      "int32",
+			},
+			"lastMigration": {
+				Description: "The name of the last migration completed during the current upgrade of the " + operatorName + " resource",
+				Type:        "string",
+			},
+			"upgradeInProgress": {
+				Description: "The upgrade of the " + operatorName + " resources in progress",
+				Type:        "object",
+				Properties: map[string]extv1.JSONSchemaProps{
+					"fromVersion": {
+						Description: "The version the upgrade started from",
+						Type:        "string",
+					},
+					"toVersion": {
+						Description: "The version being upgraded to",
+						Type:        "string",
+					},
+					"startTime": {
+						Description: "The time the upgrade started",
+						Type:        "string",
+						Format:      "date-time",
+					},
+					"upgradedResources": {
+						Description: "The number of managed resources reconciled to the target version",
+						Type:        "integer",
+						Format:      "int32",
+					},
+					"totalResources": {
+						Description: "The number of managed resources",
+						Type:        "integer",
+						Format:      "int32",
+					},
+				},
+				Required: []string{
+					"toVersion",
+					"startTime",
+					"upgradedResources",
+					"totalResources",
+				},
+			},
+			"upgradeHistory": {
+				Description: "The finished upgrades of the " + operatorName + " resources, the most recent first",
+				Type:        "array",
+				Items: &extv1.JSONSchemaPropsOrArray{
+					Schema: &extv1.JSONSchemaProps{
+						Type:        "object",
+						Description: "UpgradeRecord represents a finished upgrade",
+						Properties: map[string]extv1.JSONSchemaProps{
+							"fromVersion": {
+								Description: "The version the upgrade started from",
+								Type:        "string",
+							},
+							"toVersion": {
+								Description: "The version upgraded to",
+								Type:        "string",
+							},
+							"startTime": {
+								Description: "The time the upgrade started",
+								Type:        "string",
+								Format:      "date-time",
+							},
+							"finishTime": {
+								Description: "The time the upgrade finished",
+								Type:        "string",
+								Format:      "date-time",
+							},
+							"result": {
+								Description: "The result of the upgrade",
+								Type:        "string",
+							},
+						},
+						Required: []string{
+							"toVersion",
+							"startTime",
+							"finishTime",
+							"result",
+						},
+					},
+				},
+			},
+			"inventory": {
+				Description: "The resources applied by the " + operatorName + " operator",
+				Type:        "array",
+				Items: &extv1.JSONSchemaPropsOrArray{
+					Schema: &extv1.JSONSchemaProps{
+						Type:        "object",
+						Description: "ManagedResource identifies a resource applied by the operator",
+						Properties: map[string]extv1.JSONSchemaProps{
+							"group": {
+								Description: "The API group of the resource",
+								Type:        "string",
+							},
+							"version": {
+								Description: "The API version of the resource",
+								Type:        "string",
+							},
+							"kind": {
+								Description: "The kind of the resource",
+								Type:        "string",
+							},
+							"namespace": {
+								Description: "The namespace of the resource; empty for cluster-scoped resources",
+								Type:        "string",
+							},
+							"name": {
+								Description: "The name of the resource",
+								Type:        "string",
+							},
+							"operatorVersion": {
+								Description: "The operator version, which last applied the resource",
+								Type:        "string",
+							},
+						},
+						Required: []string{
+							"version",
+							"kind",
+							"name",
+						},
+					},
+				},
+			},
+			"inventoryInitialized": {
+				Description: "Whether the inventory lists all the resources applied by the " + operatorName + " operator",
+				Type:        "boolean",
+			},
 			"phase": {
 				Description: "Phase is the current phase of the " + operatorName + " deployment",
 				Type:        "string",
@@ -22,7 +22,6 @@ import (
 	"reflect"
 	"strings"
 
-	v1 "k8s.io/api/core/v1"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 
 	jsondiff "github.com/appscode/jsonpatch"
@@ -30,6 +29,7 @@ import (
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
 	"k8s.io/apimachinery/pkg/util/mergepatch"
@@ -42,25 +42,58 @@ var log = logf.Log.WithName("sdk")
 
 func MergeLabelsAndAnnotations(src, dest metav1.Object) {
 	// allow users to add labels but not change ours. The operator supplies the src, so if someone altered dest it will get restored.
-	for k, v := range src.GetLabels() {
-		if dest.GetLabels() == nil {
-			dest.SetLabels(map[string]string{})
+	// labels and annotations are set back, because unstructured objects return copies of them
+	if srcLabels := src.GetLabels(); len(srcLabels) > 0 {
+		labels := dest.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
 		}
-
-		dest.GetLabels()[k] = v
+		for k, v := range srcLabels {
+			labels[k] = v
+		}
+		dest.SetLabels(labels)
 	}
 
 	// same for annotations
-	for k, v := range src.GetAnnotations() {
-		if dest.GetAnnotations() == nil {
-			dest.SetAnnotations(map[string]string{})
+	if srcAnnotations := src.GetAnnotations(); len(srcAnnotations) > 0 {
+		annotations := dest.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
 		}
-
-		dest.GetAnnotations()[k] = v
+		for k, v := range srcAnnotations {
+			annotations[k] = v
+		}
+		dest.SetAnnotations(annotations)
 	}
 }
 
+// MergeObject merges desired object into the current one using three-way JSON merge patch
 func MergeObject(desiredObj, currentObj runtime.Object, lastAppliedConfigAnnotation string) (runtime.Object, error) {
+	original, modified, current, err := mergeInput(desiredObj, currentObj, lastAppliedConfigAnnotation)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current, mergePreconditions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	newCurrent, err := jsonpatch.MergePatch(current, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewDefaultInstance(currentObj)
+	if err = json.Unmarshal(newCurrent, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// mergeInput provides original (last applied), modified (desired) and current configuration for a three-way merge
+func mergeInput(desiredObj, currentObj runtime.Object, lastAppliedConfigAnnotation string) ([]byte, []byte, []byte, error) {
 	desiredObj = desiredObj.DeepCopyObject()
 	desiredMetaObj := desiredObj.(metav1.Object)
 	currentMetaObj := currentObj.(metav1.Object)
@@ -75,36 +108,23 @@ func MergeObject(desiredObj, currentObj runtime.Object, lastAppliedConfigAnnotat
 	desiredMetaObj.SetCreationTimestamp(currentMetaObj.GetCreationTimestamp())
 	modified, err := json.Marshal(desiredObj)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	current, err := json.Marshal(currentObj)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	preconditions := []mergepatch.PreconditionFunc{
+	return original, modified, current, nil
+}
+
+func mergePreconditions() []mergepatch.PreconditionFunc {
+	return []mergepatch.PreconditionFunc{
 		mergepatch.RequireKeyUnchanged("apiVersion"),
 		mergepatch.RequireKeyUnchanged("kind"),
 		mergepatch.RequireMetadataKeyUnchanged("name"),
 	}
-
-	patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current, preconditions...)
-	if err != nil {
-		return nil, err
-	}
-
-	newCurrent, err := jsonpatch.MergePatch(current, patch)
-	if err != nil {
-		return nil, err
-	}
-
-	result := NewDefaultInstance(currentObj)
-	if err = json.Unmarshal(newCurrent, result); err != nil {
-		return nil, err
-	}
-
-	return result, nil
 }
 
 func StripStatusFromObject(obj runtime.Object) (runtime.Object, error) {
@@ -149,6 +169,23 @@ func LogJSONDiff(logger logr.Logger, objA, objB interface{}) {
 	logger.Info("DIFF", "obj", objA, "patch", string(pBytes))
 }
 
+// CreateJSONPatch creates JSON patch transforming objA to objB
+func CreateJSONPatch(objA, objB interface{}) ([]byte, error) {
+	aBytes, err := json.Marshal(objA)
+	if err != nil {
+		return nil, err
+	}
+	bBytes, err := json.Marshal(objB)
+	if err != nil {
+		return nil, err
+	}
+	patches, err := jsondiff.CreatePatch(aBytes, bBytes)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(patches)
+}
+
 func CheckDeploymentReady(deployment *appsv1.Deployment) bool {
 	desiredReplicas := deployment.Spec.Replicas
 	if desiredReplicas == nil {
@@ -164,6 +201,11 @@ func CheckDeploymentReady(deployment *appsv1.Deployment) bool {
 }
 
 func NewDefaultInstance(obj runtime.Object) runtime.Object {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		result := &unstructured.Unstructured{}
+		result.SetGroupVersionKind(u.GroupVersionKind())
+		return result
+	}
 	typ := reflect.ValueOf(obj).Elem().Type()
 	return reflect.New(typ).Interface().(runtime.Object)
 }
@@ -177,19 +219,30 @@ func ContainsStringValue(values []string, value string) bool {
 	return false
 }
 
+// IsMutable tells whether the content of given object can be changed by the users, i.e. whether the default merge
+// strategy of its kind keeps the current object
+//
+// Deprecated: use MergeStrategyRegistry.StrategyFor instead
 func IsMutable(obj runtime.Object) bool {
-	switch obj.(type) {
-	case *v1.ConfigMap, *v1.Secret:
-		return true
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvks, _, err := builtInScheme.ObjectKinds(obj)
+		if err != nil {
+			return false
+		}
+		gvk = gvks[0]
 	}
-	return false
+	strategy := NewMergeStrategyRegistry().StrategyFor(gvk)
+	return reflect.ValueOf(strategy).Pointer() == reflect.ValueOf(KeepCurrentObject).Pointer()
 }
 
 func SetLabel(key, value string, obj metav1.Object) {
-	if obj.GetLabels() == nil {
-		obj.SetLabels(make(map[string]string))
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
 	}
-	obj.GetLabels()[key] = value
+	labels[key] = value
+	obj.SetLabels(labels)
 }
 
 func SameResource(obj1, obj2 runtime.Object) bool {
@@ -202,9 +255,19 @@ func SameResource(obj1, obj2 runtime.Object) bool {
 		return false
 	}
 
+	// unstructured objects of different kinds share the same Go type
+	if isUnstructured(obj1) && obj1.GetObjectKind().GroupVersionKind() != obj2.GetObjectKind().GroupVersionKind() {
+		return false
+	}
+
 	return true
 }
 
+func isUnstructured(obj runtime.Object) bool {
+	_, ok := obj.(*unstructured.Unstructured)
+	return ok
+}
+
 // SetLastAppliedConfiguration writes last applied configuration to given annotation
 func SetLastAppliedConfiguration(obj metav1.Object, lastAppliedConfigAnnotation string) error {
 	bytes, err := json.Marshal(obj)
@@ -212,11 +275,12 @@ func SetLastAppliedConfiguration(obj metav1.Object, lastAppliedConfigAnnotation
 		return err
 	}
 
-	if obj.GetAnnotations() == nil {
-		obj.SetAnnotations(make(map[string]string))
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
 	}
-
-	obj.GetAnnotations()[lastAppliedConfigAnnotation] = string(bytes)
+	annotations[lastAppliedConfigAnnotation] = string(bytes)
+	obj.SetAnnotations(annotations)
 
 	return nil
 }
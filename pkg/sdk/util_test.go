@@ -196,6 +196,21 @@ var _ = Describe("StripStatusFromObject", func() {
 
 })
 
+var _ = Describe("CreateJSONPatch", func() {
+	It("Should create patch transforming the first object to the second one", func() {
+		a := createPod("pod", map[string]string{"a": "1"}, nil)
+		b := createPod("pod", map[string]string{"a": "2"}, nil)
+
+		patch, err := CreateJSONPatch(a, b)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(patch)).To(Equal(`[{"op":"replace","path":"/metadata/labels/a","value":"2"}]`))
+
+		patch, err = CreateJSONPatch(a, a)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(patch)).To(Equal("[]"))
+	})
+})
+
 func createPod(name string, labels, annotations map[string]string) *corev1.Pod {
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -210,3 +225,11 @@ func createPod(name string, labels, annotations map[string]string) *corev1.Pod {
 	}
 	return pod
 }
+
+var _ = Describe("IsMutable", func() {
+	It("Should be true for ConfigMaps and Secrets only", func() {
+		Expect(IsMutable(&corev1.ConfigMap{})).To(BeTrue())
+		Expect(IsMutable(&corev1.Secret{})).To(BeTrue())
+		Expect(IsMutable(&v1.Deployment{})).To(BeFalse())
+	})
+})